@@ -0,0 +1,15 @@
+package api
+
+import "encoding/json"
+
+// AuditEntryDto es una entrada de GET /audit
+type AuditEntryDto struct {
+	ID         uint            `json:"id"`
+	Timestamp  string          `json:"timestamp"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`      // create, update, delete, schedule, cancel o fire
+	EntityType string          `json:"entity_type"` // person, kill o task
+	EntityID   uint            `json:"entity_id"`
+	RequestID  string          `json:"request_id,omitempty"`
+	Changes    json.RawMessage `json:"changes"` // {"campo": {"before": ..., "after": ...}}
+}
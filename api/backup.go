@@ -0,0 +1,25 @@
+package api
+
+// BackupManifestDto es manifest.json, la cabecera de un backup completo
+type BackupManifestDto struct {
+	Format     string          `json:"format"`  // siempre "deathnote-backup"
+	Version    int             `json:"version"` // versión del formato del archivo
+	CreatedAt  string          `json:"created_at"`
+	AppVersion string          `json:"app_version"`
+	Counts     BackupCountsDto `json:"counts"`
+	// MissingPhotos son las fotos referenciadas que no estaban en uploads/
+	MissingPhotos []string `json:"missing_photos,omitempty"`
+}
+
+type BackupCountsDto struct {
+	People int `json:"people"`
+	Kills  int `json:"kills"`
+	Tasks  int `json:"tasks"`
+	Photos int `json:"photos"`
+}
+
+// BackupRestoreReportDto es la respuesta de POST /admin/restore
+type BackupRestoreReportDto struct {
+	RestoredAt string          `json:"restored_at"`
+	Counts     BackupCountsDto `json:"counts"`
+}
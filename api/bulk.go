@@ -0,0 +1,19 @@
+package api
+
+// BulkImportReportDto es el informe de POST /people/bulk, una entrada por
+// fila del manifiesto
+type BulkImportReportDto struct {
+	Mode    string             `json:"mode"` // "atomic" o "partial"
+	Total   int                `json:"total"`
+	Created int                `json:"created"`
+	Failed  int                `json:"failed"`
+	Rows    []BulkRowResultDto `json:"rows"`
+}
+
+type BulkRowResultDto struct {
+	Row      int      `json:"row"` // 1 = primera fila de datos
+	Name     string   `json:"name"`
+	Status   string   `json:"status"` // "created" o "failed"
+	PersonID *uint    `json:"person_id,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
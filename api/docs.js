@@ -0,0 +1,186 @@
+// Renderiza /openapi.json sin dependencias externas: se sirve desde el
+// binario y funciona sin conexión y con una CSP estricta (sin inline ni CDN)
+(function () {
+  'use strict';
+
+  var METHODS = ['get', 'post', 'put', 'patch', 'delete'];
+  var main = document.getElementById('docs');
+  var toc = document.getElementById('toc');
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === 'text') node.textContent = attrs[k];
+      else node.setAttribute(k, attrs[k]);
+    });
+    (children || []).forEach(function (c) { if (c) node.appendChild(c); });
+    return node;
+  }
+
+  function resolve(spec, obj) {
+    while (obj && obj.$ref) {
+      obj = obj.$ref.replace(/^#\//, '').split('/').reduce(function (o, k) {
+        return o && o[k.replace(/~1/g, '/').replace(/~0/g, '~')];
+      }, spec);
+    }
+    return obj || {};
+  }
+
+  function typeName(schema) {
+    if (!schema) return '';
+    if (schema.$ref) return schema.$ref.split('/').pop();
+    if (schema.type === 'array') return typeName(schema.items) + '[]';
+    var t = schema.type || (schema.oneOf ? 'oneOf' : schema.anyOf ? 'anyOf' : 'object');
+    if (schema.format) t += ' (' + schema.format + ')';
+    if (schema.enum) t += ': ' + schema.enum.join(' | ');
+    return t;
+  }
+
+  function typeCell(schema) {
+    var td = el('td');
+    if (schema && (schema.$ref || (schema.items && schema.items.$ref))) {
+      var name = (schema.$ref || schema.items.$ref).split('/').pop();
+      td.appendChild(el('a', { href: '#schema-' + name, class: 'type', text: typeName(schema) }));
+    } else {
+      td.appendChild(el('span', { class: 'type', text: typeName(schema) }));
+    }
+    return td;
+  }
+
+  function anchor(method, path) {
+    return 'op-' + method + '-' + path.replace(/[^a-zA-Z0-9]+/g, '-');
+  }
+
+  function parametersTable(spec, params) {
+    if (!params.length) return null;
+    var rows = params.map(function (p) {
+      p = resolve(spec, p);
+      return el('tr', {}, [
+        el('td', {}, [el('code', { text: p.name }), p.required ? el('span', { class: 'required', text: ' obligatorio' }) : null]),
+        el('td', { text: p.in }),
+        typeCell(p.schema),
+        el('td', { text: p.description || '' })
+      ]);
+    });
+    return el('table', {}, [el('tr', {}, ['Parámetro', 'En', 'Tipo', 'Descripción'].map(function (h) {
+      return el('th', { text: h });
+    }))].concat(rows));
+  }
+
+  function requestBody(spec, body) {
+    if (!body) return null;
+    body = resolve(spec, body);
+    var rows = Object.keys(body.content || {}).map(function (ct) {
+      return el('tr', {}, [el('td', {}, [el('code', { text: ct })]), typeCell(body.content[ct].schema)]);
+    });
+    return el('div', {}, [el('h4', { text: 'Cuerpo' + (body.required ? ' (obligatorio)' : '') }), el('table', {}, rows)]);
+  }
+
+  function responses(spec, resps) {
+    var rows = Object.keys(resps || {}).map(function (code) {
+      var r = resolve(spec, resps[code]);
+      var content = r.content || {};
+      var ct = Object.keys(content)[0];
+      return el('tr', {}, [
+        el('td', {}, [el('code', { text: code })]),
+        el('td', { text: r.description || '' }),
+        ct ? typeCell(content[ct].schema) : el('td')
+      ]);
+    });
+    return el('div', {}, [el('h4', { text: 'Respuestas' }), el('table', {}, rows)]);
+  }
+
+  function operation(spec, path, item, method) {
+    var op = item[method];
+    var params = (item.parameters || []).concat(op.parameters || []);
+    return el('article', { id: anchor(method, path), class: 'op' + (op.deprecated ? ' deprecated' : '') }, [
+      el('div', { class: 'op-head' }, [
+        el('span', { class: 'method ' + method, text: method }),
+        el('span', { class: 'path', text: path }),
+        el('span', { class: 'summary', text: op.summary || '' })
+      ]),
+      op.description ? el('p', { text: op.description }) : null,
+      parametersTable(spec, params),
+      requestBody(spec, op.requestBody),
+      responses(spec, op.responses)
+    ]);
+  }
+
+  function schemaSection(name, schema) {
+    var required = schema.required || [];
+    var props = schema.properties || {};
+    var rows = Object.keys(props).map(function (p) {
+      return el('tr', {}, [
+        el('td', {}, [el('code', { text: p }), required.indexOf(p) >= 0 ? el('span', { class: 'required', text: ' obligatorio' }) : null]),
+        typeCell(props[p]),
+        el('td', { text: props[p].description || '' })
+      ]);
+    });
+    return el('article', { id: 'schema-' + name, class: 'op' }, [
+      el('h3', { text: name }),
+      schema.description ? el('p', { text: schema.description }) : null,
+      rows.length ? el('table', {}, rows) : el('p', {}, [el('span', { class: 'type', text: typeName(schema) })])
+    ]);
+  }
+
+  function render(spec) {
+    main.textContent = '';
+    toc.textContent = '';
+    var info = spec.info || {};
+    main.appendChild(el('h1', { text: (info.title || 'API') + (info.version ? ' ' + info.version : '') }));
+    if (info.description) main.appendChild(el('p', { text: info.description }));
+
+    var byTag = {};
+    var order = (spec.tags || []).map(function (t) { return t.name; });
+    Object.keys(spec.paths || {}).forEach(function (path) {
+      var item = spec.paths[path];
+      METHODS.forEach(function (method) {
+        if (!item[method]) return;
+        var tag = (item[method].tags || ['default'])[0];
+        if (order.indexOf(tag) < 0) order.push(tag);
+        (byTag[tag] = byTag[tag] || []).push([path, item, method]);
+      });
+    });
+
+    order.forEach(function (tag) {
+      var ops = byTag[tag];
+      if (!ops) return;
+      var meta = (spec.tags || []).filter(function (t) { return t.name === tag; })[0] || {};
+      toc.appendChild(el('h2', { text: tag }));
+      var section = el('section', { id: 'tag-' + tag, class: 'tag' }, [el('h2', { text: tag })]);
+      if (meta.description) section.appendChild(el('p', { text: meta.description }));
+      ops.forEach(function (o) {
+        toc.appendChild(el('a', { href: '#' + anchor(o[2], o[0]), text: o[2].toUpperCase() + ' ' + o[0] }));
+        section.appendChild(operation(spec, o[0], o[1], o[2]));
+      });
+      main.appendChild(section);
+    });
+
+    var schemas = (spec.components || {}).schemas || {};
+    var names = Object.keys(schemas).sort();
+    if (names.length) {
+      toc.appendChild(el('h2', { text: 'Esquemas' }));
+      var section = el('section', { id: 'schemas', class: 'tag' }, [el('h2', { text: 'Esquemas' })]);
+      names.forEach(function (name) {
+        toc.appendChild(el('a', { href: '#schema-' + name, text: name }));
+        section.appendChild(schemaSection(name, schemas[name]));
+      });
+      main.appendChild(section);
+    }
+    if (location.hash) {
+      var target = document.getElementById(location.hash.slice(1));
+      if (target) target.scrollIntoView();
+    }
+  }
+
+  fetch(main.getAttribute('data-spec-url'))
+    .then(function (resp) {
+      if (!resp.ok) throw new Error('HTTP ' + resp.status);
+      return resp.json();
+    })
+    .then(render)
+    .catch(function (err) {
+      main.textContent = '';
+      main.appendChild(el('p', { class: 'error', text: 'No se pudo cargar la especificación: ' + err.message }));
+    });
+})();
@@ -0,0 +1,33 @@
+package api
+
+type HealthResponseDto struct {
+	Status string `json:"status"`
+}
+
+type CheckResultDto struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ReadinessResponseDto struct {
+	Status string                    `json:"status"`
+	Checks map[string]CheckResultDto `json:"checks"`
+}
+
+type DBPoolStatsDto struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+}
+
+type StatusResponseDto struct {
+	Version       string          `json:"version"`
+	StartedAt     string          `json:"started_at"`
+	Uptime        string          `json:"uptime"`
+	UptimeSeconds int64           `json:"uptime_seconds"`
+	PendingTasks  int             `json:"pending_tasks"`
+	DB            *DBPoolStatsDto `json:"db,omitempty"`
+}
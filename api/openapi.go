@@ -0,0 +1,39 @@
+package api
+
+import "embed"
+
+// OpenAPISpec es la especificación OpenAPI 3 de la API, mantenida a mano en
+// openapi.json; cualquier ruta nueva en server.GetRouter debe añadirse allí
+//
+//go:embed openapi.json
+var OpenAPISpec []byte
+
+// DocsPage es la página de /docs sin Redoc; DocsScript y DocsStyle la
+// renderizan a partir de /openapi.json sin cargar nada de fuera del binario
+var (
+	//go:embed docs.html
+	DocsPage []byte
+	//go:embed docs.js
+	DocsScript []byte
+	//go:embed docs.css
+	DocsStyle []byte
+)
+
+// Redoc es el directorio redoc/, donde se vendoriza el bundle standalone de
+// Redoc con su licencia (ver redoc/README.md). RedocPage es la página de
+// /docs que lo carga
+var (
+	//go:embed redoc
+	Redoc embed.FS
+	//go:embed redoc.html
+	RedocPage []byte
+)
+
+// RedocBundle devuelve redoc.standalone.js, o nil si no está vendorizado
+func RedocBundle() []byte {
+	bundle, err := Redoc.ReadFile("redoc/redoc.standalone.js")
+	if err != nil {
+		return nil
+	}
+	return bundle
+}
@@ -0,0 +1,57 @@
+package api
+
+import "encoding/json"
+
+// NotificationDto es lo que el relay del outbox entrega a cada sink. ID es
+// el del mensaje: con entrega al menos una vez, el receptor lo usa para
+// descartar duplicados
+type NotificationDto struct {
+	ID         uint            `json:"id"`
+	Type       string          `json:"type"` // person.created, cause.added, details.added, person.died o kill.created
+	OccurredAt string          `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// PersonNotificationDto son los datos de las notificaciones de una
+// persona: person.created, cause.added, details.added y person.died.
+// DeathTime solo viene cuando ya murió
+type PersonNotificationDto struct {
+	PersonID  uint    `json:"person_id"`
+	Name      string  `json:"name"`
+	Age       int     `json:"age"`
+	Cause     *string `json:"cause,omitempty"`
+	Details   *string `json:"details,omitempty"`
+	DeathTime string  `json:"death_time,omitempty"`
+}
+
+// KillCreatedDto son los datos de kill.created
+type KillCreatedDto struct {
+	KillID      uint   `json:"kill_id"`
+	PersonID    uint   `json:"person_id"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// OutboxMessageDto es un mensaje de GET /outbox con el estado de su entrega
+// en cada sink
+type OutboxMessageDto struct {
+	ID            uint                 `json:"id"`
+	Type          string               `json:"type"`
+	PersonID      uint                 `json:"person_id"`
+	Status        string               `json:"status"` // pending, delivered o failed
+	Attempts      int                  `json:"attempts"`
+	LastError     string               `json:"last_error,omitempty"`
+	CreatedAt     string               `json:"created_at"`
+	NextAttemptAt *string              `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *string              `json:"delivered_at,omitempty"`
+	Deliveries    []*OutboxDeliveryDto `json:"deliveries"`
+	Data          json.RawMessage      `json:"data"`
+}
+
+type OutboxDeliveryDto struct {
+	Sink        string  `json:"sink"`
+	Status      string  `json:"status"`
+	Attempts    int     `json:"attempts"`
+	LastError   string  `json:"last_error,omitempty"`
+	DeliveredAt *string `json:"delivered_at,omitempty"`
+}
@@ -1,8 +1,8 @@
 package api
 
 type PersonRequestDto struct {
-	Nombre string `form:"name"` // vendrá en multipart form
-	Edad   int32  `form:"age"`
+	Nombre string `json:"name" form:"name"` // en POST vendrá en multipart form, en PUT como JSON
+	Edad   int32  `json:"age" form:"age"`
 	// esto tendre que mandarlo en multipart debido a que el json puro no acepta imagenes asi que toco multipart form data
 }
 
@@ -17,9 +17,3 @@ type PersonResponseDto struct {
 	Details       *string `json:"details,omitempty"`
 	DeathTime     *string `json:"death_time,omitempty"`
 }
-
-type ErrorResponse struct {
-	Status      int    `json:"status"`
-	Description string `json:"description"`
-	Message     string `json:"message"`
-}
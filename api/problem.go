@@ -0,0 +1,31 @@
+package api
+
+// ProblemContentType es el media type de RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// Problem es el cuerpo de todas las respuestas de error (RFC 7807)
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"` // solo en errores de validación
+}
+
+// FieldError describe un campo que no cumple el esquema de la API
+type FieldError struct {
+	In      string `json:"in"` // path, query, header o body
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ProblemTypeDto es una entrada del catálogo de códigos de error
+type ProblemTypeDto struct {
+	Code   string `json:"code"`
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+}
@@ -0,0 +1,18 @@
+package api
+
+import "encoding/json"
+
+// TimelineDto es la respuesta de GET /people/{id}/timeline
+type TimelineDto struct {
+	PersonID uint                `json:"person_id"`
+	Events   []*TimelineEventDto `json:"events"`
+}
+
+type TimelineEventDto struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	Timestamp string          `json:"timestamp"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
@@ -0,0 +1,9 @@
+package api
+
+// TrashedPersonDto es una persona en la papelera (GET /people/trash). PurgeAt
+// solo viene si hay retención configurada
+type TrashedPersonDto struct {
+	Person    *PersonV2Dto `json:"person"`
+	DeletedAt string       `json:"deleted_at"`
+	PurgeAt   *string      `json:"purge_at,omitempty"`
+}
@@ -0,0 +1,31 @@
+package api
+
+// DTOs de /v2: nombres en inglés, fechas RFC 3339 y el mismo DTO completo de
+// persona en todas las respuestas. Las peticiones usan los mismos DTOs que /v1
+
+type PersonV2Dto struct {
+	ID        uint    `json:"id"`
+	Name      string  `json:"name"`
+	Age       int     `json:"age"`
+	PhotoURL  string  `json:"photo_url"`
+	Status    string  `json:"status"` // "pending" o "dead"
+	Cause     *string `json:"cause"`
+	Details   *string `json:"details"`
+	DeathTime *string `json:"death_time"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type KillV2Dto struct {
+	ID          uint         `json:"id"`
+	PersonID    uint         `json:"person_id"`
+	Description string       `json:"description"`
+	CreatedAt   string       `json:"created_at"`
+	Person      *PersonV2Dto `json:"person"`
+}
+
+type KillTaskV2Dto struct {
+	Person     *PersonV2Dto `json:"person"`
+	Status     string       `json:"status"` // "scheduled"
+	ExecutesAt string       `json:"executes_at"`
+}
@@ -0,0 +1,29 @@
+package api
+
+// WebhookRequestDto es el cuerpo de POST /webhooks. Secret firma los envíos
+// y no se vuelve a mostrar
+type WebhookRequestDto struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+type WebhookDto struct {
+	ID        uint     `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// WebhookDeliveryDto es un intento de envío del registro de entregas
+type WebhookDeliveryDto struct {
+	ID         uint   `json:"id"`
+	MessageID  uint   `json:"message_id"` // 0 en las pruebas
+	Event      string `json:"event"`
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"status_code,omitempty"` // sin respuesta no hay código
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	CreatedAt  string `json:"created_at"`
+}
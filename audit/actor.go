@@ -0,0 +1,29 @@
+// Package audit guarda en el contexto quién hace cada escritura, para que
+// los repositorios y la cola de tareas lo anoten en el registro de auditoría
+package audit
+
+import "context"
+
+const (
+	// ActorAnonymous es el actor de las peticiones que no se identifican
+	ActorAnonymous = "anonymous"
+	// ActorAdmin es el actor de las peticiones con el token de administración
+	ActorAdmin = "admin"
+	// ActorSystem es el actor de las tareas que ejecuta la cola
+	ActorSystem = "system"
+)
+
+type actorKey struct{}
+
+// WithActor guarda el actor en el contexto
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext devuelve el actor o ActorAnonymous si no hay
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorAnonymous
+}
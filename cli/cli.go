@@ -0,0 +1,185 @@
+// Package cli implementa los subcomandos del binario: serve (por defecto),
+// backup y restore. Las tareas programadas solo viven en la memoria del
+// servidor, así que backup y restore hablan con un servidor en marcha a
+// través de /admin en lugar de abrir la base de datos
+package cli
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/server"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	defaultServerURL = "http://localhost:8000"
+	serverEnv        = "DEATHNOTE_SERVER"
+	tokenEnv         = "DEATHNOTE_ADMIN_TOKEN"
+)
+
+const usage = `Uso:
+  deathnote [serve]                    arranca el servidor
+  deathnote backup [-o archivo.zip]    descarga un backup completo
+  deathnote restore archivo.zip        restaura un backup en una BD vacía
+
+backup y restore aceptan -server (o %s, por defecto %s)
+y -token (o %s).
+`
+
+// Run ejecuta el subcomando de args y devuelve el código de salida
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "serve" {
+		server.NewServer().StartServer()
+		return 0
+	}
+	switch args[0] {
+	case "backup":
+		return backup(args[1:], stdout, stderr)
+	case "restore":
+		return restore(args[1:], stdout, stderr)
+	case "help", "-h", "--help":
+		fmt.Fprintf(stdout, usage, serverEnv, defaultServerURL, tokenEnv)
+		return 0
+	}
+	fmt.Fprintf(stderr, "comando desconocido %q\n\n", args[0])
+	fmt.Fprintf(stderr, usage, serverEnv, defaultServerURL, tokenEnv)
+	return 2
+}
+
+// adminClient llama a las rutas /admin de un servidor
+type adminClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+func newFlagSet(name string, stderr io.Writer) (*flag.FlagSet, *adminClient) {
+	c := &adminClient{http: http.DefaultClient}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&c.server, "server", envOr(serverEnv, defaultServerURL), "URL del servidor")
+	fs.StringVar(&c.token, "token", os.Getenv(tokenEnv), "token de administración")
+	return fs, c
+}
+
+func (c *adminClient) do(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(c.server, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, problemError(resp)
+	}
+	return resp, nil
+}
+
+func backup(args []string, stdout, stderr io.Writer) int {
+	fs, c := newFlagSet("backup", stderr)
+	output := fs.String("o", "", "archivo de salida (por defecto el nombre que propone el servidor)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	resp, err := c.do(http.MethodGet, "/admin/backup", "", nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "backup: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	name := *output
+	if name == "" {
+		name = attachmentName(resp, "deathnote-backup.zip")
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(stderr, "backup: %v\n", err)
+		return 1
+	}
+	written, err := io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+		fmt.Fprintf(stderr, "backup: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "backup guardado en %s (%d bytes)\n", name, written)
+	return 0
+}
+
+func restore(args []string, stdout, stderr io.Writer) int {
+	fs, c := newFlagSet("restore", stderr)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "restore: falta el archivo del backup")
+		return 2
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "restore: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+	resp, err := c.do(http.MethodPost, "/admin/restore", "application/zip", f)
+	if err != nil {
+		fmt.Fprintf(stderr, "restore: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var report api.BackupRestoreReportDto
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		fmt.Fprintf(stderr, "restore: invalid response: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "restaurado: %d personas, %d kills, %d tareas, %d fotos\n",
+		report.Counts.People, report.Counts.Kills, report.Counts.Tasks, report.Counts.Photos)
+	return 0
+}
+
+// problemError convierte una respuesta problem+json en un error legible
+func problemError(resp *http.Response) error {
+	var problem api.Problem
+	if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil || problem.Code == "" {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return fmt.Errorf("server returned %s: %s (%s)", resp.Status, problem.Detail, problem.Code)
+}
+
+// attachmentName lee el nombre de Content-Disposition, sin directorios
+func attachmentName(resp *http.Response, fallback string) string {
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition"))
+	if err != nil || params["filename"] == "" || strings.ContainsAny(params["filename"], `/\`) {
+		return fallback
+	}
+	return params["filename"]
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
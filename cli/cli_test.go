@@ -0,0 +1,59 @@
+package cli_test
+
+import (
+	"backend-avanzada/cli"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackupAndRestoreCommands(t *testing.T) {
+	var restored []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secreto" {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"status":401,"code":"unauthorized","detail":"a valid admin bearer token is required"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/admin/backup":
+			w.Header().Set("Content-Type", "application/zip")
+			io.WriteString(w, "zip")
+		case "/admin/restore":
+			restored, _ = io.ReadAll(r.Body)
+			io.WriteString(w, `{"restored_at":"2026-10-19T00:00:00Z","counts":{"people":2,"kills":1,"tasks":1,"photos":2}}`)
+		}
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "backup.zip")
+	var stdout, stderr bytes.Buffer
+	if code := cli.Run([]string{"backup", "-server", srv.URL, "-token", "secreto", "-o", out}, &stdout, &stderr); code != 0 {
+		t.Fatalf("backup: código %d: %s", code, stderr.String())
+	}
+	if data, _ := os.ReadFile(out); string(data) != "zip" {
+		t.Errorf("contenido del backup = %q", data)
+	}
+
+	stdout.Reset()
+	if code := cli.Run([]string{"restore", "-server", srv.URL, "-token", "secreto", out}, &stdout, &stderr); code != 0 {
+		t.Fatalf("restore: código %d: %s", code, stderr.String())
+	}
+	if string(restored) != "zip" || !strings.Contains(stdout.String(), "2 personas") {
+		t.Errorf("restore: recibido %q, salida %q", restored, stdout.String())
+	}
+
+	stderr.Reset()
+	if code := cli.Run([]string{"backup", "-server", srv.URL, "-token", "mal", "-o", out}, &stdout, &stderr); code != 1 {
+		t.Errorf("con token inválido esperaba código 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "unauthorized") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
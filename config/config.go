@@ -5,4 +5,17 @@ type Config struct {
 	Database                    string `json:"database"`
 	KillDuration                int    `json:"kill_duration"`
 	KillDurationWithDescription int    `json:"kill_duration_with_desc"`
+	LogFormat                   string `json:"log_format"`            // "text" o "json"
+	AccessLogFormat             string `json:"access_log_format"`     // "common", "combined" (ambos con ruta y latencia al final) o "json"
+	TracingExporter             string `json:"tracing_exporter"`      // "none", "otlp", "stdout" o "memory"
+	OTLPEndpoint                string `json:"otlp_endpoint"`         // p. ej. http://otel-collector:4318
+	LegacyDeprecatedAt          string `json:"legacy_deprecated_at"`  // RFC 3339, rutas sin /v1
+	LegacySunsetAt              string `json:"legacy_sunset_at"`      // RFC 3339, rutas sin /v1
+	IdempotencyTTL              int    `json:"idempotency_ttl"`       // segundos; 0 = 24 h
+	AdminToken                  string `json:"admin_token"`           // Bearer de /admin; vacío = deshabilitado
+	TrashRetentionDays          int    `json:"trash_retention_days"`  // días en la papelera; 0 = sin purga
+	OutboxInterval              int    `json:"outbox_interval"`       // segundos entre pasadas del relay; 0 = 5
+	OutboxWebhookURL            string `json:"outbox_webhook_url"`    // sink webhook del outbox; vacío = sin él
+	OutboxFile                  string `json:"outbox_file"`           // sink fichero JSON Lines; vacío = sin él
+	WebhookAllowPrivate         bool   `json:"webhook_allow_private"` // suscripciones a loopback y redes privadas; solo pruebas
 }
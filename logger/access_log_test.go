@@ -0,0 +1,61 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"backend-avanzada/logger"
+)
+
+func testEntry() *logger.AccessEntry {
+	return &logger.AccessEntry{
+		Time:      time.Date(2025, 5, 3, 10, 0, 0, 0, time.UTC),
+		ClientIP:  "127.0.0.1",
+		Method:    "GET",
+		URI:       "/people/7",
+		Proto:     "HTTP/1.1",
+		Route:     "/people/{id}",
+		Status:    200,
+		Size:      42,
+		Latency:   3 * time.Millisecond,
+		Referer:   "http://localhost:5173/",
+		UserAgent: "curl/8.0",
+	}
+}
+
+func TestAccessCommonAndCombined(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.FormatText, &buf)
+
+	l.Access(context.Background(), logger.AccessCommon, testEntry())
+	want := `127.0.0.1 - - [03/May/2025:10:00:00 +0000] "GET /people/7 HTTP/1.1" 200 42 "/people/{id}" 3000` + "\n"
+	if buf.String() != want {
+		t.Errorf("common:\n got %q\nwant %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	l.Access(context.Background(), logger.AccessCombined, testEntry())
+	if !strings.HasSuffix(buf.String(), `200 42 "http://localhost:5173/" "curl/8.0" "/people/{id}" 3000`+"\n") {
+		t.Errorf("combined inesperado: %q", buf.String())
+	}
+}
+
+func TestAccessJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.FormatJSON, &buf)
+
+	ctx := logger.WithRequestID(context.Background(), "req-1")
+	l.Access(ctx, logger.AccessJSON, testEntry())
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("el log no es JSON: %v (%s)", err, buf.String())
+	}
+	if entry["route"] != "/people/{id}" || entry["status"] != float64(200) || entry["request_id"] != "req-1" {
+		t.Errorf("entrada inesperada: %v", entry)
+	}
+}
@@ -1,35 +1,64 @@
 package logger
 
 import (
-	"log"
-	"net/http"
-	"time"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
 type Logger struct {
-	*log.Logger
+	*slog.Logger
+	out io.Writer
 }
 
+// NewLogger crea un logger de texto sobre stdout
 func NewLogger() *Logger {
-	return &Logger{log.New(log.Writer(), "", 0)}
+	return New(FormatText, os.Stdout)
 }
 
-func (l *Logger) RequestLogger(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		now := time.Now()
-		log.Printf("\033[44m %s \033[0m | PATH: \033[33m\"%s\"\033[0m | TIME: %v", r.Method, r.URL.Path, now.Format("2006-01-02 15:04:05"))
-		next.ServeHTTP(w, r)
-	})
+// New crea un logger estructurado; format puede ser "json" o "text" (por defecto)
+func New(format string, w io.Writer) *Logger {
+	var h slog.Handler
+	if format == FormatJSON {
+		h = slog.NewJSONHandler(w, nil)
+	} else {
+		h = slog.NewTextHandler(w, nil)
+	}
+	return &Logger{Logger: slog.New(&contextHandler{h}), out: w}
 }
 
-func (l *Logger) Info(status int, path string, start time.Time) {
-	l.Printf("\033[42m %d \033[0m | PATH: \033[33m\"%s\"\033[0m | DURATION: \033[42m %v \033[0m", status, path, time.Since(start))
+// RequestError registra una petición que terminó en error
+func (l *Logger) RequestError(ctx context.Context, status int, path string, err error) {
+	l.ErrorContext(ctx, "request failed", "status", status, "path", path, "error", err)
 }
 
-func (l *Logger) Error(status int, path string, err error) {
-	l.Printf("\033[41m %d \033[0m | PATH: \033[33m\"%s\"\033[0m | ERROR: \033[31m %v \033[0m", status, path, err)
+func (l *Logger) Fatal(err error) {
+	l.Error("fatal", "error", err)
+	os.Exit(1)
 }
 
-func (l *Logger) Fatal(err error) {
-	l.Fatalf("\033[41m FATAL \033[0m | ERROR: \033[31m %v \033[0m", err)
+// contextHandler añade a cada registro los atributos guardados en el contexto
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
 }
@@ -0,0 +1,54 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend-avanzada/logger"
+)
+
+func TestRequestIDPropagatesToLogs(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.FormatJSON, &buf)
+
+	handler := logger.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.InfoContext(r.Context(), "dentro del handler")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/people", nil)
+	req.Header.Set(logger.RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(logger.RequestIDHeader); got != "abc-123" {
+		t.Errorf("X-Request-ID = %q, esperaba abc-123", got)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("el log no es JSON: %v (%s)", err, buf.String())
+	}
+	if entry["request_id"] != "abc-123" {
+		t.Errorf("request_id = %v, esperaba abc-123", entry["request_id"])
+	}
+}
+
+func TestRequestIDGeneratedWhenInvalid(t *testing.T) {
+	handler := logger.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if logger.RequestIDFromContext(r.Context()) == "" {
+			t.Error("el contexto no tiene request id")
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/people", nil)
+	req.Header.Set(logger.RequestIDHeader, "no válido\n")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	got := rec.Header().Get(logger.RequestIDHeader)
+	if got == "" || got == "no válido\n" {
+		t.Errorf("esperaba un id generado, got %q", got)
+	}
+}
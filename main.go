@@ -1,10 +1,10 @@
 package main
 
 import (
-	"backend-avanzada/server"
+	"backend-avanzada/cli"
+	"os"
 )
 
 func main() {
-	s := server.NewServer()
-	s.StartServer()
+	os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
 }
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"backend-avanzada/api"
+	"encoding/json"
+	"time"
+)
+
+// Acciones y entidades del registro de auditoría
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	// Acciones de la cola de tareas
+	AuditTaskScheduled = "schedule"
+	AuditTaskCancelled = "cancel"
+	AuditTaskFired     = "fire"
+
+	AuditEntityPerson = "person"
+	AuditEntityKill   = "kill"
+	AuditEntityTask   = "task"
+)
+
+// AuditEntry es una entrada del registro de auditoría; solo se insertan,
+// nunca se modifican. Changes guarda {"campo": {"before": ..., "after": ...}}
+// solo con los campos que cambiaron
+type AuditEntry struct {
+	ID         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"index"`
+	Actor      string    `gorm:"size:128;index"`
+	Action     string    `gorm:"size:32"`
+	EntityType string    `gorm:"size:32;index:idx_audit_entity"`
+	EntityID   uint      `gorm:"index:idx_audit_entity"`
+	RequestID  string    `gorm:"size:128;index"`
+	Changes    string    `gorm:"type:text"`
+}
+
+func (a *AuditEntry) ToAuditEntryDto() *api.AuditEntryDto {
+	return &api.AuditEntryDto{
+		ID:         a.ID,
+		Timestamp:  a.CreatedAt.Format(time.RFC3339Nano),
+		Actor:      a.Actor,
+		Action:     a.Action,
+		EntityType: a.EntityType,
+		EntityID:   a.EntityID,
+		RequestID:  a.RequestID,
+		Changes:    json.RawMessage(a.Changes),
+	}
+}
@@ -0,0 +1,270 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tipos de evento de dominio. El estado de Person y sus kills se deriva de
+// la secuencia de eventos de su stream; people y kills son proyecciones
+const (
+	EventTypeNameWritten      = "NameWritten"
+	EventTypePersonEdited     = "PersonEdited"
+	EventTypePhotoReplaced    = "PhotoReplaced"
+	EventTypeCauseSpecified   = "CauseSpecified"
+	EventTypeDetailsSpecified = "DetailsSpecified"
+	EventTypeDeathScheduled   = "DeathScheduled"
+	EventTypeDied             = "Died"
+	EventTypePersonDeleted    = "PersonDeleted"
+	EventTypePersonRestored   = "PersonRestored"
+	EventTypeKillRecorded     = "KillRecorded"
+	EventTypeKillDeleted      = "KillDeleted"
+	EventTypeKillRestored     = "KillRestored"
+	// Los importados traen el estado completo; los escribe la restauración
+	// de backups y la migración de filas anteriores al event store
+	EventTypePersonImported = "PersonImported"
+	EventTypeKillImported   = "KillImported"
+)
+
+// DomainEvent es un evento del stream de una persona. StreamVersion empieza
+// en 1 y es único por stream, así dos escrituras concurrentes no pueden
+// añadir el mismo evento
+type DomainEvent struct {
+	ID            uint      `gorm:"primaryKey"`
+	StreamID      uint      `gorm:"not null;uniqueIndex:idx_domain_events_stream"`
+	StreamVersion uint      `gorm:"not null;uniqueIndex:idx_domain_events_stream"`
+	Type          string    `gorm:"size:32;not null"`
+	Data          string    `gorm:"type:text"`
+	Actor         string    `gorm:"size:128"`
+	RequestID     string    `gorm:"size:128"`
+	OccurredAt    time.Time `gorm:"index"`
+}
+
+// NameWritten abre el stream; la causa y los detalles vienen cuando se
+// escriben junto con el nombre (importación masiva)
+type NameWritten struct {
+	Name      string  `json:"name"`
+	Age       int     `json:"age"`
+	PhotoPath string  `json:"photo_path"`
+	Cause     *string `json:"cause,omitempty"`
+	Details   *string `json:"details,omitempty"`
+}
+
+type PersonEdited struct {
+	Name *string `json:"name,omitempty"`
+	Age  *int    `json:"age,omitempty"`
+}
+
+type PhotoReplaced struct {
+	PhotoPath string `json:"photo_path"`
+}
+
+// CauseSpecified con Cause nil borra la causa (PATCH con null)
+type CauseSpecified struct {
+	Cause *string `json:"cause"`
+}
+
+type DetailsSpecified struct {
+	Details *string `json:"details"`
+}
+
+type DeathScheduled struct {
+	Kind string    `json:"kind"`
+	At   time.Time `json:"at"`
+}
+
+// Died marca la muerte; Cause solo viene cuando la muerte la fija (ataque
+// al corazón sin causa escrita)
+type Died struct {
+	At    time.Time `json:"at"`
+	Cause *string   `json:"cause,omitempty"`
+}
+
+type PersonDeleted struct{}
+
+// PersonRestored saca a la persona de la papelera
+type PersonRestored struct{}
+
+type KillRecorded struct {
+	KillID      uint   `json:"kill_id"`
+	Description string `json:"description"`
+}
+
+// KillDeleted revoca la kill: la borra de forma lógica. Cascade indica que
+// la borró el borrado de su persona, y que vuelve si la persona se restaura
+type KillDeleted struct {
+	KillID  uint `json:"kill_id"`
+	Cascade bool `json:"cascade,omitempty"`
+}
+
+// KillRestored devuelve la kill borrada en cascada al restaurar su persona
+type KillRestored struct {
+	KillID uint `json:"kill_id"`
+}
+
+type PersonImported struct {
+	Name      string     `json:"name"`
+	Age       int        `json:"age"`
+	PhotoPath string     `json:"photo_path"`
+	Cause     *string    `json:"cause,omitempty"`
+	Details   *string    `json:"details,omitempty"`
+	DeathTime *time.Time `json:"death_time,omitempty"`
+	Version   uint       `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type KillImported struct {
+	KillID      uint       `json:"kill_id"`
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// eventTypes asocia cada payload con su tipo
+var eventTypes = map[string]func() any{
+	EventTypeNameWritten:      func() any { return &NameWritten{} },
+	EventTypePersonEdited:     func() any { return &PersonEdited{} },
+	EventTypePhotoReplaced:    func() any { return &PhotoReplaced{} },
+	EventTypeCauseSpecified:   func() any { return &CauseSpecified{} },
+	EventTypeDetailsSpecified: func() any { return &DetailsSpecified{} },
+	EventTypeDeathScheduled:   func() any { return &DeathScheduled{} },
+	EventTypeDied:             func() any { return &Died{} },
+	EventTypePersonDeleted:    func() any { return &PersonDeleted{} },
+	EventTypePersonRestored:   func() any { return &PersonRestored{} },
+	EventTypeKillRecorded:     func() any { return &KillRecorded{} },
+	EventTypeKillDeleted:      func() any { return &KillDeleted{} },
+	EventTypeKillRestored:     func() any { return &KillRestored{} },
+	EventTypePersonImported:   func() any { return &PersonImported{} },
+	EventTypeKillImported:     func() any { return &KillImported{} },
+}
+
+// NewDomainEvent crea un evento con su payload en JSON. La hora se trunca a
+// microsegundos, la precisión de PostgreSQL, para que la proyección y el
+// replay coincidan exactamente
+func NewDomainEvent(eventType string, payload any, at time.Time) (*DomainEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &DomainEvent{Type: eventType, Data: string(data), OccurredAt: at.Truncate(time.Microsecond)}, nil
+}
+
+// Payload decodifica Data según Type
+func (e *DomainEvent) Payload() (any, error) {
+	newPayload, ok := eventTypes[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown domain event type %q", e.Type)
+	}
+	payload := newPayload()
+	if err := json.Unmarshal([]byte(e.Data), payload); err != nil {
+		return nil, fmt.Errorf("event %d (%s): %w", e.ID, e.Type, err)
+	}
+	return payload, nil
+}
+
+// Apply aplica el evento a la persona. Es la única definición de cómo
+// cambia el estado: la usan tanto las escrituras (proyección) como el replay
+func (p *Person) Apply(e *DomainEvent) error {
+	payload, err := e.Payload()
+	if err != nil {
+		return err
+	}
+	bump := true
+	switch d := payload.(type) {
+	case *NameWritten:
+		if e.StreamID != 0 {
+			p.ID = e.StreamID
+		}
+		p.Name, p.Age, p.PhotoPath = d.Name, d.Age, d.PhotoPath
+		p.Cause, p.Details = d.Cause, d.Details
+		p.CreatedAt = e.OccurredAt
+		p.Version = 0
+	case *PersonEdited:
+		if d.Name != nil {
+			p.Name = *d.Name
+		}
+		if d.Age != nil {
+			p.Age = *d.Age
+		}
+	case *PhotoReplaced:
+		p.PhotoPath = d.PhotoPath
+	case *CauseSpecified:
+		p.Cause = d.Cause
+	case *DetailsSpecified:
+		p.Details = d.Details
+	case *Died:
+		at := d.At
+		p.DeathTime = &at
+		if d.Cause != nil {
+			p.Cause = d.Cause
+		}
+	case *PersonDeleted:
+		// Como el borrado lógico de GORM: solo deleted_at
+		p.DeletedAt = gorm.DeletedAt{Time: e.OccurredAt, Valid: true}
+		bump = false
+	case *PersonRestored:
+		p.DeletedAt = gorm.DeletedAt{}
+	case *PersonImported:
+		if e.StreamID != 0 {
+			p.ID = e.StreamID
+		}
+		p.Name, p.Age, p.PhotoPath = d.Name, d.Age, d.PhotoPath
+		p.Cause, p.Details, p.DeathTime = d.Cause, d.Details, d.DeathTime
+		p.Version, p.CreatedAt, p.UpdatedAt = d.Version, d.CreatedAt, d.UpdatedAt
+		p.DeletedAt = gorm.DeletedAt{}
+		if d.DeletedAt != nil {
+			p.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+		}
+		bump = false
+	default:
+		// DeathScheduled y las kills no cambian la fila de people
+		bump = false
+	}
+	if bump {
+		p.Version++
+		p.UpdatedAt = e.OccurredAt
+	}
+	return nil
+}
+
+// ApplyKills aplica el evento a las kills de la persona: las registradas se
+// añaden y las revocadas quedan con deleted_at, como en la tabla kills. Los
+// eventos que no son de kills las devuelven sin cambios
+func ApplyKills(kills []*Kill, e *DomainEvent) ([]*Kill, error) {
+	payload, err := e.Payload()
+	if err != nil {
+		return nil, err
+	}
+	switch d := payload.(type) {
+	case *KillRecorded:
+		kill := &Kill{PersonId: e.StreamID, Description: d.Description}
+		kill.ID, kill.CreatedAt, kill.UpdatedAt = d.KillID, e.OccurredAt, e.OccurredAt
+		kills = append(kills, kill)
+	case *KillImported:
+		kill := &Kill{PersonId: e.StreamID, Description: d.Description}
+		kill.ID, kill.CreatedAt, kill.UpdatedAt = d.KillID, d.CreatedAt, d.UpdatedAt
+		if d.DeletedAt != nil {
+			kill.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+		}
+		kills = append(kills, kill)
+	case *KillDeleted:
+		for _, kill := range kills {
+			if kill.ID == d.KillID {
+				kill.DeletedAt = gorm.DeletedAt{Time: e.OccurredAt, Valid: true}
+			}
+		}
+	case *KillRestored:
+		for _, kill := range kills {
+			if kill.ID == d.KillID {
+				kill.DeletedAt = gorm.DeletedAt{}
+			}
+		}
+	}
+	return kills, nil
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyKey guarda la respuesta de una petición de creación para
+// repetirla si el cliente reintenta con la misma Idempotency-Key
+type IdempotencyKey struct {
+	// Key es el hash de método, plantilla de ruta e Idempotency-Key
+	Key         string `gorm:"primaryKey;size:255"`
+	RequestHash string `gorm:"size:64;not null"`
+	// Status es 0 mientras la petición original sigue en curso
+	Status    int
+	Header    []byte // cabeceras de la respuesta en JSON
+	Body      []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
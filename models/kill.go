@@ -2,6 +2,7 @@ package models
 
 import (
 	"backend-avanzada/api"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -19,3 +20,26 @@ func (k *Kill) ToKillResponseDto() *api.KillResponseDto {
 		Description: k.Description,
 	}
 }
+
+func (k *Kill) ToKillV2Dto() *api.KillV2Dto {
+	dto := &api.KillV2Dto{
+		ID:          k.ID,
+		PersonID:    k.PersonId,
+		Description: k.Description,
+		CreatedAt:   k.CreatedAt.Format(time.RFC3339),
+	}
+	if k.Person != nil {
+		dto.Person = k.Person.ToPersonV2Dto()
+	}
+	return dto
+}
+
+// ToKillCreatedDto son los datos de la notificación kill.created
+func (k *Kill) ToKillCreatedDto() *api.KillCreatedDto {
+	return &api.KillCreatedDto{
+		KillID:      k.ID,
+		PersonID:    k.PersonId,
+		Description: k.Description,
+		CreatedAt:   k.CreatedAt.Format(time.RFC3339Nano),
+	}
+}
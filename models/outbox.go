@@ -0,0 +1,140 @@
+package models
+
+import (
+	"backend-avanzada/api"
+	"encoding/json"
+	"time"
+)
+
+// Tipos de mensaje del outbox: lo que otros sistemas quieren saber
+const (
+	TopicPersonCreated = "person.created"
+	TopicCauseAdded    = "cause.added"
+	TopicDetailsAdded  = "details.added"
+	TopicPersonDied    = "person.died"
+	TopicKillCreated   = "kill.created"
+)
+
+// Topics son todos los tipos de mensaje del outbox, en el orden del ciclo
+// de vida de una persona
+var Topics = []string{TopicPersonCreated, TopicCauseAdded, TopicDetailsAdded, TopicPersonDied, TopicKillCreated}
+
+// Estados de un mensaje del outbox y de su entrega en cada sink
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxFailed    = "failed" // agotó los intentos; no se reintenta
+)
+
+// OutboxMessage es una notificación escrita en la misma transacción que el
+// cambio que la produce, así no se pierde aunque el proceso caiga antes de
+// publicarla. El relay la entrega a los sinks y lleva el estado
+type OutboxMessage struct {
+	ID            uint      `gorm:"primaryKey"`
+	Topic         string    `gorm:"size:32;not null;index"`
+	PersonID      uint      `gorm:"index"`
+	Payload       string    `gorm:"type:text"`
+	Status        string    `gorm:"size:16;not null;index:idx_outbox_due"`
+	Attempts      int       `gorm:"not null;default:0"`
+	LastError     string    `gorm:"type:text"`
+	NextAttemptAt time.Time `gorm:"index:idx_outbox_due"`
+	CreatedAt     time.Time
+	DeliveredAt   *time.Time
+	Deliveries    []*OutboxDelivery `gorm:"foreignKey:MessageID"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox"
+}
+
+// OutboxDelivery es el estado de un mensaje en un sink. Un sink que ya lo
+// recibió no lo vuelve a recibir cuando se reintenta por otro que falló, y
+// cada sink lleva sus intentos y su siguiente intento
+type OutboxDelivery struct {
+	ID            uint   `gorm:"primaryKey"`
+	MessageID     uint   `gorm:"not null;uniqueIndex:idx_outbox_delivery"`
+	Sink          string `gorm:"size:64;not null;uniqueIndex:idx_outbox_delivery"`
+	Status        string `gorm:"size:16;not null"`
+	Attempts      int    `gorm:"not null;default:0"`
+	LastError     string `gorm:"type:text"`
+	NextAttemptAt time.Time
+	DeliveredAt   *time.Time
+	UpdatedAt     time.Time
+}
+
+// NewOutboxMessage crea un mensaje pendiente con payload en JSON, listo
+// para entregarse desde at
+func NewOutboxMessage(topic string, personID uint, payload any, at time.Time) (*OutboxMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &OutboxMessage{
+		Topic:         topic,
+		PersonID:      personID,
+		Payload:       string(data),
+		Status:        OutboxPending,
+		NextAttemptAt: at,
+		CreatedAt:     at,
+	}, nil
+}
+
+// ToNotificationDto es el mensaje tal como lo reciben los sinks
+func (m *OutboxMessage) ToNotificationDto() *api.NotificationDto {
+	return &api.NotificationDto{
+		ID:         m.ID,
+		Type:       m.Topic,
+		OccurredAt: m.CreatedAt.Format(time.RFC3339Nano),
+		Data:       json.RawMessage(m.Payload),
+	}
+}
+
+func (m *OutboxMessage) ToOutboxMessageDto() *api.OutboxMessageDto {
+	dto := &api.OutboxMessageDto{
+		ID:         m.ID,
+		Type:       m.Topic,
+		PersonID:   m.PersonID,
+		Status:     m.Status,
+		Attempts:   m.Attempts,
+		LastError:  m.LastError,
+		CreatedAt:  m.CreatedAt.Format(time.RFC3339Nano),
+		Deliveries: make([]*api.OutboxDeliveryDto, 0, len(m.Deliveries)),
+		Data:       json.RawMessage(m.Payload),
+	}
+	if m.Status == OutboxPending {
+		next := m.NextAttemptAt.Format(time.RFC3339Nano)
+		dto.NextAttemptAt = &next
+	}
+	dto.DeliveredAt = formatTime(m.DeliveredAt)
+	for _, d := range m.Deliveries {
+		dto.Deliveries = append(dto.Deliveries, &api.OutboxDeliveryDto{
+			Sink:        d.Sink,
+			Status:      d.Status,
+			Attempts:    d.Attempts,
+			LastError:   d.LastError,
+			DeliveredAt: formatTime(d.DeliveredAt),
+		})
+	}
+	return dto
+}
+
+// Delivery devuelve la entrega del mensaje en sink, creándola pendiente si
+// todavía no se había intentado
+func (m *OutboxMessage) Delivery(sink string) *OutboxDelivery {
+	for _, d := range m.Deliveries {
+		if d.Sink == sink {
+			return d
+		}
+	}
+	d := &OutboxDelivery{MessageID: m.ID, Sink: sink, Status: OutboxPending}
+	m.Deliveries = append(m.Deliveries, d)
+	return d
+}
+
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	formatted := t.Format(time.RFC3339Nano)
+	return &formatted
+}
@@ -15,6 +15,9 @@ type Person struct {
 	Cause     *string
 	Details   *string
 	DeathTime *time.Time
+	// Version se incrementa en cada escritura; es la base del ETag y del
+	// bloqueo optimista
+	Version uint `gorm:"not null;default:1"`
 }
 
 // computeStatus devuelve el estado de la persona
@@ -47,3 +50,46 @@ func (p *Person) ToPersonResponseDto() *api.PersonResponseDto {
 		DeathTime:     deathTimeStr,
 	}
 }
+
+// computeStatusV2 es computeStatus con los valores en inglés de /v2
+func computeStatusV2(p *Person) string {
+	if p.DeathTime == nil {
+		return "pending"
+	}
+	return "dead"
+}
+
+func (p *Person) ToPersonV2Dto() *api.PersonV2Dto {
+	var deathTime *string
+	if p.DeathTime != nil {
+		ts := p.DeathTime.Format(time.RFC3339)
+		deathTime = &ts
+	}
+	return &api.PersonV2Dto{
+		ID:        p.ID,
+		Name:      p.Name,
+		Age:       p.Age,
+		PhotoURL:  p.PhotoPath,
+		Status:    computeStatusV2(p),
+		Cause:     p.Cause,
+		Details:   p.Details,
+		DeathTime: deathTime,
+		CreatedAt: p.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: p.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// ToPersonNotificationDto son los datos de las notificaciones de la persona
+func (p *Person) ToPersonNotificationDto() *api.PersonNotificationDto {
+	dto := &api.PersonNotificationDto{
+		PersonID: p.ID,
+		Name:     p.Name,
+		Age:      p.Age,
+		Cause:    p.Cause,
+		Details:  p.Details,
+	}
+	if p.DeathTime != nil {
+		dto.DeathTime = p.DeathTime.Format(time.RFC3339Nano)
+	}
+	return dto
+}
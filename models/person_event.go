@@ -0,0 +1,51 @@
+package models
+
+import (
+	"backend-avanzada/api"
+	"encoding/json"
+	"time"
+)
+
+// Tipos de evento de la línea de tiempo de una persona
+const (
+	EventNameWritten     = "name_written"
+	EventAgeChanged      = "age_changed"
+	EventPhotoUploaded   = "photo_uploaded"
+	EventCauseAdded      = "cause_added"
+	EventDetailsAdded    = "details_added"
+	EventTaskScheduled   = "task_scheduled"
+	EventTaskCancelled   = "task_cancelled"
+	EventTaskRescheduled = "task_rescheduled"
+	EventTaskFailed      = "task_failed"
+	EventDied            = "died"
+	EventKillRecorded    = "kill_recorded"
+	EventKillRevoked     = "kill_revoked"
+	EventDeleted         = "deleted"
+	EventRestored        = "restored"
+)
+
+// PersonEvent es un hecho de la vida de una persona tal como lo registran
+// los handlers y la cola de tareas; Data es JSON con los valores del evento
+type PersonEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	PersonID  uint      `gorm:"index"`
+	Type      string    `gorm:"size:32"`
+	Actor     string    `gorm:"size:128"`
+	RequestID string    `gorm:"size:128"`
+	Data      string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (e *PersonEvent) ToTimelineEventDto() *api.TimelineEventDto {
+	dto := &api.TimelineEventDto{
+		ID:        e.ID,
+		Type:      e.Type,
+		Timestamp: e.CreatedAt.Format(time.RFC3339Nano),
+		Actor:     e.Actor,
+		RequestID: e.RequestID,
+	}
+	if e.Data != "" {
+		dto.Data = json.RawMessage(e.Data)
+	}
+	return dto
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PersonVersion es una fila de la historia temporal de people: el estado
+// que tuvo la persona entre ValidFrom (incluido) y ValidTo (excluido). La
+// fila vigente tiene ValidTo nil; una persona borrada no tiene fila vigente
+type PersonVersion struct {
+	ID              uint `gorm:"primaryKey"`
+	PersonID        uint `gorm:"not null;index"`
+	Name            string
+	Age             int
+	PhotoPath       string
+	Cause           *string
+	Details         *string
+	DeathTime       *time.Time
+	Version         uint
+	PersonCreatedAt time.Time
+	ValidFrom       time.Time  `gorm:"not null;index"`
+	ValidTo         *time.Time `gorm:"index"`
+}
+
+// NewPersonVersion abre una fila de historia con el estado de p desde from
+func NewPersonVersion(p *Person, from time.Time) *PersonVersion {
+	return &PersonVersion{
+		PersonID:        p.ID,
+		Name:            p.Name,
+		Age:             p.Age,
+		PhotoPath:       p.PhotoPath,
+		Cause:           p.Cause,
+		Details:         p.Details,
+		DeathTime:       p.DeathTime,
+		Version:         p.Version,
+		PersonCreatedAt: p.CreatedAt,
+		ValidFrom:       from,
+	}
+}
+
+// ToPerson devuelve la persona tal como era mientras la fila estuvo vigente
+func (v *PersonVersion) ToPerson() *Person {
+	return &Person{
+		Model: gorm.Model{
+			ID:        v.PersonID,
+			CreatedAt: v.PersonCreatedAt,
+			UpdatedAt: v.ValidFrom,
+		},
+		Name:      v.Name,
+		Age:       v.Age,
+		PhotoPath: v.PhotoPath,
+		Cause:     v.Cause,
+		Details:   v.Details,
+		DeathTime: v.DeathTime,
+		Version:   v.Version,
+	}
+}
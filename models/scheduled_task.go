@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// ScheduledTask es una tarea pendiente de la cola, escrita en la misma
+// transacción que el cambio que la programa. La cola en memoria se arma
+// desde esta fila después del commit y, al arrancar, desde las que quedaron
+// pendientes, así una tarea no se pierde si el proceso cae. La fila se
+// borra cuando la tarea se ejecuta o se cancela
+type ScheduledTask struct {
+	// PersonID es también el id de la tarea: una persona tiene como mucho una
+	PersonID    uint      `gorm:"primaryKey;autoIncrement:false"`
+	Kind        string    `gorm:"size:16;not null"`
+	RunAt       time.Time `gorm:"not null;index"`
+	Description string    `gorm:"type:text"` // solo en kind "kill"
+	CreatedAt   time.Time
+}
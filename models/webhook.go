@@ -0,0 +1,77 @@
+package models
+
+import (
+	"backend-avanzada/api"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TopicWebhookTest es el tipo de la notificación de POST /webhooks/{id}/test;
+// no pasa por el outbox
+const TopicWebhookTest = "webhook.test"
+
+// Webhook es una suscripción: las notificaciones de los tipos de Events se
+// envían firmadas con Secret a URL
+type Webhook struct {
+	ID     uint   `gorm:"primaryKey"`
+	URL    string `gorm:"type:text;not null"`
+	Events string `gorm:"type:text;not null"` // tipos separados por comas
+	Secret string `gorm:"size:256;not null"`
+	// Failures son los envíos fallidos seguidos; hasta RetryAt no se le
+	// envía nada
+	Failures  int `gorm:"not null;default:0"`
+	RetryAt   *time.Time
+	CreatedAt time.Time
+}
+
+// EventList son los tipos de notificación a los que está suscrito
+func (w *Webhook) EventList() []string {
+	if w.Events == "" {
+		return []string{}
+	}
+	return strings.Split(w.Events, ",")
+}
+
+func (w *Webhook) Subscribed(topic string) bool {
+	return slices.Contains(w.EventList(), topic)
+}
+
+// ToWebhookDto nunca incluye el secreto
+func (w *Webhook) ToWebhookDto() *api.WebhookDto {
+	return &api.WebhookDto{
+		ID:        w.ID,
+		URL:       w.URL,
+		Events:    w.EventList(),
+		CreatedAt: w.CreatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+// WebhookDelivery es un intento de envío a una suscripción. MessageID es el
+// mensaje del outbox; 0 en las pruebas de POST /webhooks/{id}/test
+type WebhookDelivery struct {
+	ID         uint   `gorm:"primaryKey"`
+	WebhookID  uint   `gorm:"not null;index:idx_webhook_deliveries_message"`
+	MessageID  uint   `gorm:"not null;index:idx_webhook_deliveries_message"`
+	Event      string `gorm:"size:32;not null"`
+	Attempt    int    `gorm:"not null"`
+	StatusCode int
+	Success    bool
+	Error      string `gorm:"type:text"`
+	DurationMs int64
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+func (d *WebhookDelivery) ToWebhookDeliveryDto() *api.WebhookDeliveryDto {
+	return &api.WebhookDeliveryDto{
+		ID:         d.ID,
+		MessageID:  d.MessageID,
+		Event:      d.Event,
+		Attempt:    d.Attempt,
+		StatusCode: d.StatusCode,
+		Success:    d.Success,
+		Error:      d.Error,
+		DurationMs: d.DurationMs,
+		CreatedAt:  d.CreatedAt.Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,222 @@
+// Package outbox entrega a otros sistemas las notificaciones que los
+// repositorios escriben en la tabla outbox, con entrega al menos una vez
+package outbox
+
+import (
+	"backend-avanzada/logger"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize   = 100
+	defaultMaxAttempts = 10
+	// defaultPassTimeout es lo que puede durar una pasada: cumplido, los
+	// mensajes que faltan esperan a la siguiente
+	defaultPassTimeout = 30 * time.Second
+	baseBackoff        = time.Second
+	maxBackoff         = time.Hour
+)
+
+// Relay lee los mensajes pendientes del outbox y los entrega a cada sink.
+// Un mensaje queda entregado cuando todos los sinks lo aceptaron. Los sinks
+// reciben cada mensaje a la vez y cada uno lleva sus intentos y su espera
+// exponencial: si uno falla se reintenta solo ese, hasta agotar sus
+// intentos, y durante el resto de la pasada no se le envía nada más
+type Relay struct {
+	repo        *repository.OutboxRepository
+	logger      *logger.Logger
+	batchSize   int
+	maxAttempts int
+	passTimeout time.Duration
+
+	// flushMu serializa las pasadas; sinksMu protege la lista de sinks
+	flushMu sync.Mutex
+	sinksMu sync.RWMutex
+	sinks   []Sink
+}
+
+func NewRelay(repo *repository.OutboxRepository, l *logger.Logger, sinks ...Sink) *Relay {
+	return &Relay{
+		repo:        repo,
+		logger:      l,
+		batchSize:   defaultBatchSize,
+		maxAttempts: defaultMaxAttempts,
+		passTimeout: defaultPassTimeout,
+		sinks:       sinks,
+	}
+}
+
+// AddSink añade un sink; recibe también los mensajes aún pendientes
+func (r *Relay) AddSink(sink Sink) {
+	r.sinksMu.Lock()
+	defer r.sinksMu.Unlock()
+	r.sinks = append(r.sinks, sink)
+}
+
+func (r *Relay) currentSinks() []Sink {
+	r.sinksMu.RLock()
+	defer r.sinksMu.RUnlock()
+	return append([]Sink(nil), r.sinks...)
+}
+
+// Run entrega los mensajes pendientes cada interval hasta que ctx termine
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+				r.logger.ErrorContext(ctx, "outbox relay failed", "error", err)
+			}
+		}
+	}
+}
+
+// Flush hace una pasada: intenta entregar los mensajes cuyo siguiente
+// intento ya llegó y guarda el resultado de cada uno. Pasado passTimeout no
+// empieza más mensajes. Sin sinks no hace nada y los mensajes siguen
+// pendientes. Devuelve cuántos quedaron entregados
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+	sinks := r.currentSinks()
+	if len(sinks) == 0 {
+		return 0, nil
+	}
+	repo := r.repo.WithContext(ctx)
+	messages, err := repo.Due(time.Now(), r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+	deadline := time.Now().Add(r.passTimeout)
+	failing := map[string]bool{}
+	delivered := 0
+	for _, m := range messages {
+		if time.Now().After(deadline) {
+			break
+		}
+		r.attempt(ctx, m, sinks, failing)
+		// Si no se puede guardar, el mensaje sigue pendiente y se vuelve a
+		// entregar: al menos una vez
+		if err := repo.SaveAttempt(m); err != nil {
+			return delivered, err
+		}
+		if m.Status == models.OutboxDelivered {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// attempt entrega m a la vez a los sinks que aún no lo recibieron y cuyo
+// siguiente intento ya llegó, y actualiza su estado. failing son los sinks
+// que ya fallaron en esta pasada: no se les vuelve a intentar hasta la
+// siguiente, así un receptor caído no frena al resto
+func (r *Relay) attempt(ctx context.Context, m *models.OutboxMessage, sinks []Sink, failing map[string]bool) {
+	now := time.Now()
+	m.Attempts++
+	notification := m.ToNotificationDto()
+	due := make([]*models.OutboxDelivery, len(sinks))
+	errs := make([]error, len(sinks))
+	var wg sync.WaitGroup
+	for i, sink := range sinks {
+		d := m.Delivery(sink.Name())
+		if d.Status != models.OutboxPending || d.NextAttemptAt.After(now) || failing[sink.Name()] {
+			continue
+		}
+		due[i] = d
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = sink.Deliver(ctx, notification)
+		}()
+	}
+	wg.Wait()
+
+	for i, sink := range sinks {
+		d := due[i]
+		if d == nil {
+			continue
+		}
+		if errs[i] == nil {
+			d.Status, d.LastError, d.DeliveredAt = models.OutboxDelivered, "", &now
+			continue
+		}
+		d.LastError = errs[i].Error()
+		// Un sink que lleva sus propios intentos (el de suscripciones) dice
+		// cuándo volver; no cuenta como fallo suyo
+		var later *RetryLater
+		if errors.As(errs[i], &later) {
+			d.NextAttemptAt = later.At
+			if !d.NextAttemptAt.After(now) {
+				d.NextAttemptAt = now.Add(baseBackoff)
+			}
+			continue
+		}
+		failing[sink.Name()] = true
+		d.Attempts++
+		r.logger.WarnContext(ctx, "outbox delivery failed", "message_id", m.ID, "sink", sink.Name(), "attempt", d.Attempts, "error", errs[i])
+		if d.Attempts >= r.maxAttempts {
+			d.Status = models.OutboxFailed
+			continue
+		}
+		d.NextAttemptAt = now.Add(Backoff(d.Attempts))
+	}
+	settle(m, now)
+}
+
+// settle decide el estado del mensaje a partir del de sus entregas:
+// pendiente hasta el intento más cercano si falta alguna, fallido si las
+// que faltan agotaron sus intentos y entregado si todas llegaron
+func settle(m *models.OutboxMessage, now time.Time) {
+	var failures []string
+	var next *time.Time
+	failed := false
+	for _, d := range m.Deliveries {
+		if d.Status != models.OutboxDelivered && d.LastError != "" {
+			failures = append(failures, d.Sink+": "+d.LastError)
+		}
+		switch d.Status {
+		case models.OutboxFailed:
+			failed = true
+		case models.OutboxPending:
+			at := d.NextAttemptAt
+			if at.Before(now) {
+				at = now
+			}
+			if next == nil || at.Before(*next) {
+				next = &at
+			}
+		}
+	}
+	m.LastError = strings.Join(failures, "; ")
+	switch {
+	case next != nil:
+		m.NextAttemptAt = *next
+	case failed:
+		m.Status = models.OutboxFailed
+	default:
+		m.Status, m.DeliveredAt = models.OutboxDelivered, &now
+	}
+}
+
+// Backoff es la espera antes del intento siguiente a attempt: 1s, 2s, 4s...
+// hasta una hora
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		return baseBackoff
+	}
+	if attempt > 12 {
+		return maxBackoff
+	}
+	return min(baseBackoff<<(attempt-1), maxBackoff)
+}
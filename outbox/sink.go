@@ -0,0 +1,150 @@
+package outbox
+
+import (
+	"backend-avanzada/api"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sink recibe las notificaciones del outbox. Name identifica al sink en el
+// estado de entrega de cada mensaje, así que debe ser único y estable entre
+// arranques. Un error hace que el mensaje se reintente más tarde, también
+// si el sink llegó a recibirlo: el receptor descarta duplicados por ID
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, n *api.NotificationDto) error
+}
+
+// RetryLater lo devuelve un sink que lleva sus propios intentos cuando aún
+// le quedan envíos pendientes: el relay no lo cuenta como fallo del sink y
+// vuelve a pasarle el mensaje en At
+type RetryLater struct {
+	At  time.Time
+	Err error
+}
+
+func (e *RetryLater) Error() string {
+	return fmt.Sprintf("%v (retry at %s)", e.Err, e.At.Format(time.RFC3339))
+}
+
+func (e *RetryLater) Unwrap() error {
+	return e.Err
+}
+
+// ChannelSink entrega las notificaciones a un canal del propio proceso
+type ChannelSink struct {
+	name string
+	ch   chan<- *api.NotificationDto
+}
+
+func NewChannelSink(name string, ch chan<- *api.NotificationDto) *ChannelSink {
+	return &ChannelSink{
+		name: name,
+		ch:   ch,
+	}
+}
+
+func (c *ChannelSink) Name() string {
+	return c.name
+}
+
+// Deliver espera a que el canal acepte la notificación o a que ctx termine
+func (c *ChannelSink) Deliver(ctx context.Context, n *api.NotificationDto) error {
+	select {
+	case c.ch <- n:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FileSink añade cada notificación como una línea JSON al final de un
+// fichero
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{
+		path: path,
+	}
+}
+
+func (f *FileSink) Name() string {
+	return "file"
+}
+
+// Deliver escribe la línea y la lleva a disco antes de dar la entrega por
+// buena
+func (f *FileSink) Deliver(_ context.Context, n *api.NotificationDto) error {
+	line, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// webhookTimeout limita cada POST del WebhookSink
+const webhookTimeout = 10 * time.Second
+
+// WebhookSink envía cada notificación con un POST JSON a una URL; cualquier
+// respuesta fuera de 2xx es un fallo
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (w *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (w *WebhookSink) Deliver(ctx context.Context, n *api.NotificationDto) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Notification-Id", strconv.FormatUint(uint64(n.ID), 10))
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}
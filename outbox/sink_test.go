@@ -0,0 +1,67 @@
+package outbox_test
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/outbox"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func notification(id uint) *api.NotificationDto {
+	return &api.NotificationDto{ID: id, Type: "person.died", OccurredAt: "2026-10-19T12:00:00Z", Data: json.RawMessage(`{"person_id":1}`)}
+}
+
+func TestFileSinkAppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	sink := outbox.NewFileSink(path)
+	for id := uint(1); id <= 2; id++ {
+		if err := sink.Deliver(context.Background(), notification(id)); err != nil {
+			t.Fatalf("Deliver() error: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"id":2`) {
+		t.Errorf("fichero inesperado: %s", data)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	status := http.StatusNoContent
+	var got api.NotificationDto
+	var id string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		id = r.Header.Get("X-Notification-Id")
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+	sink := outbox.NewWebhookSink(receiver.URL)
+
+	if err := sink.Deliver(context.Background(), notification(7)); err != nil {
+		t.Fatalf("Deliver() error: %v", err)
+	}
+	if got.ID != 7 || got.Type != "person.died" || id != "7" {
+		t.Errorf("recibido %+v con id %q", got, id)
+	}
+	status = http.StatusInternalServerError
+	if err := sink.Deliver(context.Background(), notification(8)); err == nil {
+		t.Error("un 500 debería ser un fallo")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: time.Hour}
+	for attempt, want := range cases {
+		if got := outbox.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, esperado %v", attempt, got, want)
+		}
+	}
+}
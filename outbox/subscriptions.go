@@ -0,0 +1,188 @@
+package outbox
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Cabeceras de los envíos a las suscripciones
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// Sign es la firma de body con secret: "sha256=" y el HMAC-SHA256 en
+// hexadecimal. El receptor la recalcula sobre el cuerpo tal como llega
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SubscriptionSink envía cada notificación a las suscripciones de
+// POST /webhooks de su tipo, firmada con el secreto de cada una, y anota
+// cada intento en el registro de entregas. Las suscripciones reciben a la
+// vez y cada una lleva sus intentos por mensaje (hasta maxAttempts) y su
+// espera exponencial: una que falla no recibe nada hasta su RetryAt, y las
+// demás no esperan por ella. Mientras quede alguna pendiente devuelve
+// RetryLater para que el relay vuelva a pasarle el mensaje
+type SubscriptionSink struct {
+	repo         *repository.WebhookRepository
+	client       *http.Client
+	allowPrivate bool
+	maxAttempts  int
+}
+
+// NewSubscriptionSink crea el sink; allowPrivate permite suscripciones a
+// loopback y redes privadas (solo para pruebas y entornos cerrados)
+func NewSubscriptionSink(repo *repository.WebhookRepository, allowPrivate bool) *SubscriptionSink {
+	return &SubscriptionSink{
+		repo:         repo,
+		client:       newSubscriptionClient(allowPrivate),
+		allowPrivate: allowPrivate,
+		maxAttempts:  defaultMaxAttempts,
+	}
+}
+
+func (s *SubscriptionSink) Name() string {
+	return "webhooks"
+}
+
+func (s *SubscriptionSink) Deliver(ctx context.Context, n *api.NotificationDto) error {
+	repo := s.repo.WithContext(ctx)
+	webhooks, err := repo.Subscribed(n.Type)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		errs     []error
+		failures []error
+		retryAt  time.Time
+	)
+	later := func(at time.Time, failure error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if retryAt.IsZero() || at.Before(retryAt) {
+			retryAt = at
+		}
+		failures = append(failures, failure)
+	}
+	for _, webhook := range webhooks {
+		attempts, delivered, err := repo.Attempts(webhook.ID, n.ID)
+		if err != nil {
+			return err
+		}
+		if delivered || attempts >= s.maxAttempts {
+			// Entregada o abandonada; el registro de entregas lo muestra
+			continue
+		}
+		if webhook.RetryAt != nil && webhook.RetryAt.After(now) {
+			later(*webhook.RetryAt, fmt.Errorf("webhook %d: backing off after %d failure(s)", webhook.ID, webhook.Failures))
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := s.Send(ctx, webhook, n, attempts+1)
+			if err == nil {
+				err = s.recordHealth(ctx, webhook, d, now)
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+			if !d.Success && attempts+1 < s.maxAttempts {
+				later(*webhook.RetryAt, fmt.Errorf("webhook %d: %s", webhook.ID, d.Error))
+			}
+		}()
+	}
+	wg.Wait()
+	// Si no se pudo guardar algún intento, el relay reintenta el sink
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if !retryAt.IsZero() {
+		return &RetryLater{At: retryAt, Err: errors.Join(failures...)}
+	}
+	return nil
+}
+
+// recordHealth lleva los fallos seguidos de la suscripción: uno nuevo
+// aplaza sus envíos con espera exponencial y un éxito la deja al día
+func (s *SubscriptionSink) recordHealth(ctx context.Context, webhook *models.Webhook, d *models.WebhookDelivery, now time.Time) error {
+	if d.Success {
+		if webhook.Failures == 0 {
+			return nil
+		}
+		webhook.Failures, webhook.RetryAt = 0, nil
+	} else {
+		webhook.Failures++
+		retryAt := now.Add(Backoff(webhook.Failures))
+		webhook.RetryAt = &retryAt
+	}
+	return s.repo.WithContext(ctx).SaveHealth(webhook)
+}
+
+// Send hace un envío a la suscripción y lo anota en el registro de
+// entregas. El resultado del envío va en la entrega; el error es solo el de
+// guardarla
+func (s *SubscriptionSink) Send(ctx context.Context, webhook *models.Webhook, n *api.NotificationDto, attempt int) (*models.WebhookDelivery, error) {
+	d := &models.WebhookDelivery{
+		WebhookID: webhook.ID,
+		MessageID: n.ID,
+		Event:     n.Type,
+		Attempt:   attempt,
+	}
+	start := time.Now()
+	d.StatusCode, d.Error = s.post(ctx, webhook, n)
+	d.DurationMs = time.Since(start).Milliseconds()
+	d.Success = d.Error == ""
+	if err := s.repo.WithContext(ctx).LogDelivery(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// post devuelve el código de la respuesta y, si el envío falló, el motivo
+func (s *SubscriptionSink) post(ctx context.Context, webhook *models.Webhook, n *api.NotificationDto) (int, string) {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return 0, err.Error()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+	req.Header.Set(EventHeader, n.Type)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(n.ID), 10))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Sprintf("webhook responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, ""
+}
@@ -0,0 +1,91 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// ErrForbiddenTarget es el error de una suscripción que apunta a loopback,
+// una red privada, link-local u otra dirección no pública
+var ErrForbiddenTarget = errors.New("webhook target must be a public address")
+
+// reservedPrefixes son rangos no públicos que netip no clasifica
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// publicAddr indica si se puede enviar a addr
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckTarget valida la URL de una suscripción al darla de alta: http o
+// https absoluta y con un host que resuelva solo a direcciones públicas.
+// Como el DNS puede cambiar después, cada envío vuelve a comprobar la
+// dirección a la que conecta (ver newSubscriptionClient)
+func (s *SubscriptionSink) CheckTarget(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if s.allowPrivate {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", target.Hostname())
+	if err != nil {
+		return fmt.Errorf("url host %q does not resolve", target.Hostname())
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenTarget, target.Hostname(), addr)
+		}
+	}
+	return nil
+}
+
+// newSubscriptionClient es el cliente de los envíos a suscripciones. Salvo
+// con allowPrivate, rechaza al conectar cualquier dirección no pública,
+// también tras una redirección o si el DNS cambió desde el alta. No usa
+// proxy: la comprobación tiene que ver la dirección real
+func newSubscriptionClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenTarget, addrPort.Addr())
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			ForceAttemptHTTP2:   true,
+		},
+	}
+}
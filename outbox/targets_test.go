@@ -0,0 +1,48 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckTargetRejectsPrivateAddresses(t *testing.T) {
+	sink := NewSubscriptionSink(nil, false)
+	for _, target := range []string{
+		"ftp://93.184.215.14/hook",
+		"http://127.0.0.1:8000/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.7/hook",
+		"http://192.168.1.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.64.0.1/hook",
+		"http://0.0.0.0/hook",
+	} {
+		if err := sink.CheckTarget(context.Background(), target); err == nil {
+			t.Errorf("CheckTarget(%q) debería fallar", target)
+		}
+	}
+	if err := sink.CheckTarget(context.Background(), "https://93.184.215.14/hook"); err != nil {
+		t.Errorf("una IP pública debería aceptarse: %v", err)
+	}
+	if err := NewSubscriptionSink(nil, true).CheckTarget(context.Background(), "http://127.0.0.1/hook"); err != nil {
+		t.Errorf("con allowPrivate debería aceptarse: %v", err)
+	}
+}
+
+func TestSubscriptionClientRechecksAddressOnDial(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	_, err := newSubscriptionClient(false).Post(target.URL, "application/json", nil)
+	if !errors.Is(err, ErrForbiddenTarget) {
+		t.Fatalf("Post() a loopback = %v, esperaba ErrForbiddenTarget", err)
+	}
+	resp, err := newSubscriptionClient(true).Post(target.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("con allowPrivate: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"backend-avanzada/audit"
+	"backend-avanzada/logger"
+	"backend-avanzada/models"
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditFields son los valores de una entidad que se comparan para el
+// registro de auditoría; solo admite valores comparables con ==
+type AuditFields map[string]any
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{
+		db: db,
+	}
+}
+
+// WithContext devuelve una copia del repositorio cuyas consultas usan ctx
+// (trazas, cancelación, actor y request id)
+func (a *AuditRepository) WithContext(ctx context.Context) *AuditRepository {
+	return &AuditRepository{
+		db: a.db.WithContext(ctx),
+	}
+}
+
+// Record añade una entrada con el actor y el request id del contexto. Lo
+// usan los repositorios dentro de su transacción y la cola de tareas
+func (a *AuditRepository) Record(action, entityType string, entityID uint, before, after AuditFields) error {
+	return recordAudit(a.db, action, entityType, entityID, before, after)
+}
+
+// AuditFilter son los filtros de GET /audit; los campos vacíos no filtran
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityID   uint
+	RequestID  string
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+}
+
+// List devuelve las entradas que cumplen filter, de la más reciente a la
+// más antigua
+func (a *AuditRepository) List(filter AuditFilter) ([]*models.AuditEntry, error) {
+	query := a.db.Model(&models.AuditEntry{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	var entries []*models.AuditEntry
+	if err := query.Order("id DESC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// recordAudit inserta la entrada con los campos que difieren entre before
+// y after. Una actualización que no cambia nada no se registra
+func recordAudit(db *gorm.DB, action, entityType string, entityID uint, before, after AuditFields) error {
+	changes := auditChanges(before, after)
+	if action == models.AuditUpdate && len(changes) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	entry := &models.AuditEntry{
+		Actor:      audit.ActorFromContext(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		RequestID:  logger.RequestIDFromContext(ctx),
+		Changes:    string(data),
+	}
+	return db.Session(&gorm.Session{NewDB: true}).Create(entry).Error
+}
+
+type auditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+func auditChanges(before, after AuditFields) map[string]auditChange {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	changes := map[string]auditChange{}
+	for _, k := range names {
+		if before[k] != after[k] {
+			changes[k] = auditChange{Before: before[k], After: after[k]}
+		}
+	}
+	return changes
+}
+
+// personAuditFields resume la persona para el registro; nil si no existe
+func personAuditFields(p *models.Person) AuditFields {
+	if p == nil {
+		return nil
+	}
+	fields := AuditFields{
+		"name":       p.Name,
+		"age":        p.Age,
+		"photo_path": p.PhotoPath,
+		"cause":      derefString(p.Cause),
+		"details":    derefString(p.Details),
+		"death_time": formatTime(p.DeathTime),
+		"deleted_at": nil,
+	}
+	if p.DeletedAt.Valid {
+		fields["deleted_at"] = p.DeletedAt.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return fields
+}
+
+// killAuditFields resume la kill para el registro; nil si no existe
+func killAuditFields(k *models.Kill) AuditFields {
+	if k == nil {
+		return nil
+	}
+	fields := AuditFields{
+		"person_id":   k.PersonId,
+		"description": k.Description,
+		"deleted_at":  nil,
+	}
+	if k.DeletedAt.Valid {
+		fields["deleted_at"] = k.DeletedAt.Time.UTC().Format(time.RFC3339Nano)
+	}
+	return fields
+}
+
+func derefString(s *string) any {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func formatTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
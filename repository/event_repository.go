@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"backend-avanzada/audit"
+	"backend-avanzada/logger"
+	"backend-avanzada/models"
+	"context"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+type EventRepository struct {
+	db *gorm.DB
+}
+
+func NewEventRepository(db *gorm.DB) *EventRepository {
+	return &EventRepository{
+		db: db,
+	}
+}
+
+// WithContext devuelve una copia del repositorio cuyas consultas usan ctx
+// (trazas, cancelación, actor y request id)
+func (e *EventRepository) WithContext(ctx context.Context) *EventRepository {
+	return &EventRepository{
+		db: e.db.WithContext(ctx),
+	}
+}
+
+// Record añade un evento a la línea de tiempo de la persona con el actor y
+// el request id del contexto
+func (e *EventRepository) Record(personID uint, eventType string, data map[string]any) error {
+	ctx := e.db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	event := &models.PersonEvent{
+		PersonID:  personID,
+		Type:      eventType,
+		Actor:     audit.ActorFromContext(ctx),
+		RequestID: logger.RequestIDFromContext(ctx),
+	}
+	if len(data) > 0 {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		event.Data = string(encoded)
+	}
+	return e.db.Create(event).Error
+}
+
+// ListByPerson devuelve los eventos de la persona en el orden en que ocurrieron
+func (e *EventRepository) ListByPerson(personID uint) ([]*models.PersonEvent, error) {
+	var events []*models.PersonEvent
+	err := e.db.Where("person_id = ?", personID).Order("id").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"backend-avanzada/audit"
+	"backend-avanzada/logger"
+	"backend-avanzada/models"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// errConcurrentWrite indica que otra escritura cambió la persona entre la
+// lectura y la proyección; quien escribe vuelve a intentarlo
+var errConcurrentWrite = errors.New("concurrent write on person stream")
+
+// EventStore lee los streams de eventos de dominio. Las escrituras las
+// hacen PeopleRepository y KillRepository, que añaden los eventos y
+// actualizan las proyecciones en la misma transacción
+type EventStore struct {
+	db *gorm.DB
+}
+
+func NewEventStore(db *gorm.DB) *EventStore {
+	return &EventStore{
+		db: db,
+	}
+}
+
+// WithContext devuelve una copia del store cuyas consultas usan ctx
+// (trazas, cancelación)
+func (s *EventStore) WithContext(ctx context.Context) *EventStore {
+	return &EventStore{
+		db: s.db.WithContext(ctx),
+	}
+}
+
+// Stream devuelve los eventos de la persona en orden; con asOf solo los
+// ocurridos hasta ese instante
+func (s *EventStore) Stream(personID uint, asOf *time.Time) ([]*models.DomainEvent, error) {
+	query := s.db.Where("stream_id = ?", personID)
+	if asOf != nil {
+		query = query.Where("occurred_at <= ?", *asOf)
+	}
+	var events []*models.DomainEvent
+	if err := query.Order("stream_version").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Replay reconstruye la persona y sus kills, también las revocadas,
+// aplicando su stream desde el principio, sin leer las proyecciones.
+// Devuelve nil si no hay eventos
+func (s *EventStore) Replay(personID uint, asOf *time.Time) (*models.Person, []*models.Kill, error) {
+	events, err := s.Stream(personID, asOf)
+	if err != nil || len(events) == 0 {
+		return nil, nil, err
+	}
+	person := &models.Person{}
+	var kills []*models.Kill
+	for _, e := range events {
+		if err := person.Apply(e); err != nil {
+			return nil, nil, err
+		}
+		if kills, err = models.ApplyKills(kills, e); err != nil {
+			return nil, nil, err
+		}
+	}
+	return person, kills, nil
+}
+
+// Backfill abre el stream de las personas que no tienen eventos (creadas
+// antes del event store) con su estado actual y sus kills. Devuelve cuántos
+// streams abrió
+func (s *EventStore) Backfill() (int, error) {
+	var people []*models.Person
+	err := s.db.Unscoped().
+		Where("NOT EXISTS (SELECT 1 FROM domain_events WHERE domain_events.stream_id = people.id)").
+		Order("id").
+		Find(&people).Error
+	if err != nil {
+		return 0, err
+	}
+	for _, person := range people {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			event, err := models.NewDomainEvent(models.EventTypePersonImported, importedPerson(person), eventTime())
+			if err != nil {
+				return err
+			}
+			events := []*models.DomainEvent{event}
+			var kills []*models.Kill
+			if err := tx.Where("person_id = ?", person.ID).Order("id").Find(&kills).Error; err != nil {
+				return err
+			}
+			for _, kill := range kills {
+				event, err := models.NewDomainEvent(models.EventTypeKillImported, importedKill(kill), eventTime())
+				if err != nil {
+					return err
+				}
+				events = append(events, event)
+			}
+			return appendEvents(tx, person.ID, events)
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(people), nil
+}
+
+// appendEvents añade events al final del stream con el actor y el request
+// id del contexto. El índice único de (stream_id, stream_version) rechaza
+// dos escrituras que compitan por la misma posición
+func appendEvents(tx *gorm.DB, streamID uint, events []*models.DomainEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	var last uint
+	err := tx.Session(&gorm.Session{NewDB: true}).Model(&models.DomainEvent{}).
+		Where("stream_id = ?", streamID).
+		Select("COALESCE(MAX(stream_version), 0)").
+		Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for i, e := range events {
+		e.StreamID = streamID
+		e.StreamVersion = last + uint(i) + 1
+		e.Actor = audit.ActorFromContext(ctx)
+		e.RequestID = logger.RequestIDFromContext(ctx)
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).Create(events).Error
+}
+
+// eventTime es la hora de los eventos que se emiten ahora, con la precisión
+// que guarda la base de datos
+func eventTime() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// importedPerson es la foto completa de la persona para PersonImported,
+// con las fechas a la precisión de la base de datos
+func importedPerson(p *models.Person) *models.PersonImported {
+	imported := &models.PersonImported{
+		Name:      p.Name,
+		Age:       p.Age,
+		PhotoPath: p.PhotoPath,
+		Cause:     p.Cause,
+		Details:   p.Details,
+		DeathTime: truncateTime(p.DeathTime),
+		Version:   p.Version,
+		CreatedAt: p.CreatedAt.Truncate(time.Microsecond),
+		UpdatedAt: p.UpdatedAt.Truncate(time.Microsecond),
+	}
+	if p.DeletedAt.Valid {
+		imported.DeletedAt = truncateTime(&p.DeletedAt.Time)
+	}
+	return imported
+}
+
+func importedKill(k *models.Kill) *models.KillImported {
+	imported := &models.KillImported{
+		KillID:      k.ID,
+		Description: k.Description,
+		CreatedAt:   k.CreatedAt.Truncate(time.Microsecond),
+		UpdatedAt:   k.UpdatedAt.Truncate(time.Microsecond),
+	}
+	if k.DeletedAt.Valid {
+		imported.DeletedAt = truncateTime(&k.DeletedAt.Time)
+	}
+	return imported
+}
+
+func truncateTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	truncated := t.Truncate(time.Microsecond)
+	return &truncated
+}
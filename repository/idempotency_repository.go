@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: db,
+	}
+}
+
+// WithContext devuelve una copia del repositorio cuyas consultas usan ctx
+// (trazas, cancelación)
+func (i *IdempotencyRepository) WithContext(ctx context.Context) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: i.db.WithContext(ctx),
+	}
+}
+
+// FindByKey devuelve la clave si existe y no ha caducado
+func (i *IdempotencyRepository) FindByKey(key string, now time.Time) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := i.db.Where("key = ? AND expires_at > ?", key, now).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Reserve inserta la clave en curso; devuelve false si ya existía. Una
+// reserva sin respuesta creada antes de staleBefore se da por abandonada y
+// se sustituye, igual que una clave caducada que DeleteExpired aún no borró
+func (i *IdempotencyRepository) Reserve(record *models.IdempotencyKey, staleBefore time.Time) (bool, error) {
+	err := i.db.Where("key = ? AND ((status = 0 AND created_at < ?) OR expires_at <= ?)", record.Key, staleBefore, record.CreatedAt).
+		Delete(&models.IdempotencyKey{}).Error
+	if err != nil {
+		return false, err
+	}
+	result := i.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Complete guarda la respuesta de la petición original
+func (i *IdempotencyRepository) Complete(key string, status int, header, body []byte) error {
+	return i.db.Model(&models.IdempotencyKey{}).
+		Where("key = ?", key).
+		Updates(map[string]interface{}{
+			"status": status,
+			"header": header,
+			"body":   body,
+		}).Error
+}
+
+// Release borra la clave para que el cliente pueda reintentar
+func (i *IdempotencyRepository) Release(key string) error {
+	return i.db.Where("key = ?", key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// DeleteExpired borra las claves caducadas; lo ejecuta la retención cada
+// hora, no cada petición
+func (i *IdempotencyRepository) DeleteExpired(now time.Time) error {
+	return i.db.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{}).Error
+}
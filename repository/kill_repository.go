@@ -2,11 +2,17 @@ package repository
 
 import (
 	"backend-avanzada/models"
+	"context"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// ErrPersonGone indica que la persona de la kill no existe o está en la
+// papelera: una kill no puede registrarse sobre ella
+var ErrPersonGone = errors.New("person does not exist or has been deleted")
+
 type KillRepository struct {
 	db *gorm.DB
 }
@@ -17,6 +23,22 @@ func NewKillRepository(db *gorm.DB) *KillRepository {
 	}
 }
 
+// WithContext devuelve una copia del repositorio cuyas consultas usan ctx
+// (trazas, cancelación)
+func (k *KillRepository) WithContext(ctx context.Context) *KillRepository {
+	return &KillRepository{
+		db: k.db.WithContext(ctx),
+	}
+}
+
+// Unscoped devuelve una copia del repositorio cuyas consultas incluyen las
+// kills borradas
+func (k *KillRepository) Unscoped() *KillRepository {
+	return &KillRepository{
+		db: k.db.Unscoped(),
+	}
+}
+
 func (k *KillRepository) FindAll() ([]*models.Kill, error) {
 	var kills []*models.Kill
 	err := k.db.Preload("Person").Find(&kills).Error
@@ -26,9 +48,40 @@ func (k *KillRepository) FindAll() ([]*models.Kill, error) {
 	return kills, nil
 }
 
+// Save registra una kill nueva, la añade al stream de su persona como
+// KillRecorded y encola kill.created en el outbox. Las kills no se editan,
+// y no se registran sobre personas borradas (ErrPersonGone)
 func (k *KillRepository) Save(data *models.Kill) (*models.Kill, error) {
-	err := k.db.Save(data).Error
+	if data.ID != 0 {
+		return nil, errors.New("kills cannot be modified once recorded")
+	}
+	now := eventTime()
+	data.CreatedAt, data.UpdatedAt = now, now
+	err := k.db.Transaction(func(tx *gorm.DB) error {
+		person, err := findPersonUnscoped(tx, data.PersonId)
+		if err != nil {
+			return err
+		}
+		if person == nil || person.DeletedAt.Valid {
+			return ErrPersonGone
+		}
+		if err := tx.Omit("Person").Create(data).Error; err != nil {
+			return err
+		}
+		event, err := models.NewDomainEvent(models.EventTypeKillRecorded, &models.KillRecorded{KillID: data.ID, Description: data.Description}, now)
+		if err != nil {
+			return err
+		}
+		if err := appendEvents(tx, data.PersonId, []*models.DomainEvent{event}); err != nil {
+			return err
+		}
+		if err := enqueueOutbox(tx, models.TopicKillCreated, data.PersonId, data.ToKillCreatedDto(), now); err != nil {
+			return err
+		}
+		return auditKill(tx, data.ID, nil)
+	})
 	if err != nil {
+		data.ID = 0
 		return nil, err
 	}
 	return data, nil
@@ -46,6 +99,204 @@ func (k *KillRepository) FindById(id int) (*models.Kill, error) {
 	return &kill, nil
 }
 
+// Delete revoca la kill con KillDeleted en el stream de su persona; la fila
+// queda borrada de forma lógica. Devuelve gorm.ErrRecordNotFound si no
+// existe o ya estaba revocada
 func (k *KillRepository) Delete(data *models.Kill) error {
-	return errors.New("this method is not implemented")
+	return k.db.Transaction(func(tx *gorm.DB) error {
+		current, err := findKillUnscoped(tx, data.ID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.DeletedAt.Valid {
+			return gorm.ErrRecordNotFound
+		}
+		event, err := models.NewDomainEvent(models.EventTypeKillDeleted, &models.KillDeleted{KillID: current.ID}, eventTime())
+		if err != nil {
+			return err
+		}
+		events := []*models.DomainEvent{event}
+		if err := appendEvents(tx, current.PersonId, events); err != nil {
+			return err
+		}
+		return projectKills(tx, events)
+	})
+}
+
+// cascadeEvents añade a events los que arrastran a las kills de la persona:
+// borrarla revoca sus kills vivas y restaurarla devuelve las que se
+// revocaron con ella, las que tienen su mismo deleted_at
+func cascadeEvents(tx *gorm.DB, current *models.Person, events []*models.DomainEvent) ([]*models.DomainEvent, error) {
+	var cascaded []*models.DomainEvent
+	for _, e := range events {
+		var kills []*models.Kill
+		err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().
+			Where("person_id = ?", current.ID).
+			Order("id").
+			Find(&kills).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, kill := range kills {
+			var eventType string
+			var payload any
+			switch {
+			case e.Type == models.EventTypePersonDeleted && !kill.DeletedAt.Valid:
+				eventType, payload = models.EventTypeKillDeleted, &models.KillDeleted{KillID: kill.ID, Cascade: true}
+			case e.Type == models.EventTypePersonRestored && kill.DeletedAt.Valid &&
+				current.DeletedAt.Valid && kill.DeletedAt.Time.Equal(current.DeletedAt.Time):
+				eventType, payload = models.EventTypeKillRestored, &models.KillRestored{KillID: kill.ID}
+			default:
+				continue
+			}
+			event, err := models.NewDomainEvent(eventType, payload, e.OccurredAt)
+			if err != nil {
+				return nil, err
+			}
+			cascaded = append(cascaded, event)
+		}
+	}
+	return append(events, cascaded...), nil
+}
+
+// projectKills lleva a la tabla kills las revocaciones y restauraciones de
+// events y las audita
+func projectKills(tx *gorm.DB, events []*models.DomainEvent) error {
+	for _, e := range events {
+		payload, err := e.Payload()
+		if err != nil {
+			return err
+		}
+		var id uint
+		var deletedAt *time.Time
+		switch d := payload.(type) {
+		case *models.KillDeleted:
+			id, deletedAt = d.KillID, &e.OccurredAt
+		case *models.KillRestored:
+			id = d.KillID
+		default:
+			continue
+		}
+		before, err := findKillUnscoped(tx, id)
+		if err != nil {
+			return err
+		}
+		err = tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&models.Kill{}).
+			Where("id = ?", id).
+			UpdateColumn("deleted_at", deletedAt).Error
+		if err != nil {
+			return err
+		}
+		if err := auditKill(tx, id, before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// killRow es una kill con el nombre de su persona, leída con un JOIN
+type killRow struct {
+	ID          uint
+	Description string
+	PersonID    uint
+	PersonName  string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt
+}
+
+// Each recorre todas las kills por id leyendo del cursor de la base de
+// datos. La persona solo trae id y nombre, suficiente para exportar. Sobre
+// una copia Unscoped incluye las kills borradas con su deleted_at
+func (k *KillRepository) Each(fn func(kill *models.Kill) error) error {
+	rows, err := k.db.Model(&models.Kill{}).
+		Select("kills.id, kills.description, kills.person_id, kills.created_at, kills.updated_at, kills.deleted_at, people.name AS person_name").
+		Joins("LEFT JOIN people ON people.id = kills.person_id").
+		Order("kills.id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var row killRow
+		if err := k.db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		kill := &models.Kill{
+			Description: row.Description,
+			PersonId:    row.PersonID,
+			Person:      &models.Person{Name: row.PersonName},
+		}
+		kill.ID = row.ID
+		kill.CreatedAt = row.CreatedAt
+		kill.UpdatedAt = row.UpdatedAt
+		kill.DeletedAt = row.DeletedAt
+		kill.Person.ID = row.PersonID
+		if err := fn(kill); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Insert crea la kill conservando su ID y fechas; lo usa la restauración de
+// backups. Se añade al stream de su persona como KillImported
+func (k *KillRepository) Insert(data *models.Kill) error {
+	imported := importedKill(data)
+	data.CreatedAt, data.UpdatedAt = imported.CreatedAt, imported.UpdatedAt
+	return k.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Person").Create(data).Error; err != nil {
+			return err
+		}
+		event, err := models.NewDomainEvent(models.EventTypeKillImported, imported, eventTime())
+		if err != nil {
+			return err
+		}
+		if err := appendEvents(tx, data.PersonId, []*models.DomainEvent{event}); err != nil {
+			return err
+		}
+		return auditKill(tx, data.ID, nil)
+	})
+}
+
+// auditKill registra la diferencia entre before y la kill actual
+func auditKill(tx *gorm.DB, id uint, before *models.Kill) error {
+	after, err := findKillUnscoped(tx, id)
+	if err != nil {
+		return err
+	}
+	action := models.AuditUpdate
+	switch {
+	case before == nil:
+		action = models.AuditCreate
+	case after == nil || (after.DeletedAt.Valid && !before.DeletedAt.Valid):
+		action = models.AuditDelete
+	}
+	return recordAudit(tx, action, models.AuditEntityKill, id, killAuditFields(before), killAuditFields(after))
+}
+
+// findKillUnscoped lee la kill aunque esté borrada; nil si no existe
+func findKillUnscoped(tx *gorm.DB, id uint) (*models.Kill, error) {
+	var kill models.Kill
+	err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Where("id = ?", id).First(&kill).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &kill, nil
+}
+
+// CountAll cuenta las kills, incluidas las borradas
+func (k *KillRepository) CountAll() (int64, error) {
+	var count int64
+	err := k.db.Unscoped().Model(&models.Kill{}).Count(&count).Error
+	return count, err
+}
+
+// ResetIDSequence alinea la secuencia de IDs con el mayor ID guardado
+func (k *KillRepository) ResetIDSequence() error {
+	return resetIDSequence(k.db, "kills")
 }
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db: db,
+	}
+}
+
+// WithContext devuelve una copia del repositorio cuyas consultas usan ctx
+// (trazas, cancelación)
+func (o *OutboxRepository) WithContext(ctx context.Context) *OutboxRepository {
+	return &OutboxRepository{
+		db: o.db.WithContext(ctx),
+	}
+}
+
+// OutboxFilter son los filtros de GET /outbox; los vacíos no filtran
+type OutboxFilter struct {
+	Status string
+	Topic  string
+	Limit  int
+}
+
+// List devuelve los mensajes con sus entregas, del más reciente al más
+// antiguo
+func (o *OutboxRepository) List(filter OutboxFilter) ([]*models.OutboxMessage, error) {
+	query := o.db.Preload("Deliveries", func(db *gorm.DB) *gorm.DB { return db.Order("sink") })
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Topic != "" {
+		query = query.Where("topic = ?", filter.Topic)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	var messages []*models.OutboxMessage
+	if err := query.Order("id DESC").Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Due devuelve, en orden, hasta limit mensajes pendientes cuyo siguiente
+// intento ya llegó, con sus entregas
+func (o *OutboxRepository) Due(now time.Time, limit int) ([]*models.OutboxMessage, error) {
+	var messages []*models.OutboxMessage
+	err := o.db.Preload("Deliveries").
+		Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, now).
+		Order("id").
+		Limit(limit).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// SaveAttempt guarda el resultado de un intento: el estado del mensaje y el
+// de cada una de sus entregas, en una transacción
+func (o *OutboxRepository) SaveAttempt(message *models.OutboxMessage) error {
+	return o.db.Transaction(func(tx *gorm.DB) error {
+		for _, d := range message.Deliveries {
+			d.MessageID = message.ID
+			if err := tx.Session(&gorm.Session{NewDB: true}).Save(d).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Session(&gorm.Session{NewDB: true}).Model(&models.OutboxMessage{}).
+			Where("id = ?", message.ID).
+			Updates(map[string]interface{}{
+				"status":          message.Status,
+				"attempts":        message.Attempts,
+				"last_error":      message.LastError,
+				"next_attempt_at": message.NextAttemptAt,
+				"delivered_at":    message.DeliveredAt,
+			}).Error
+	})
+}
+
+// enqueueOutbox escribe el mensaje en el outbox dentro de la transacción de
+// la escritura que lo produce
+func enqueueOutbox(tx *gorm.DB, topic string, personID uint, payload any, at time.Time) error {
+	message, err := models.NewOutboxMessage(topic, personID, payload, at)
+	if err != nil {
+		return err
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).Omit("Deliveries").Create(message).Error
+}
+
+// notifyEvents encola las notificaciones de los eventos de la persona:
+// person.created al escribir el nombre, cause.added y details.added cuando
+// se fijan (no cuando se borran) y person.died con la muerte. Todas llevan
+// la persona tal como queda tras la escritura; las importaciones no
+// notifican nada
+func notifyEvents(tx *gorm.DB, person *models.Person, events []*models.DomainEvent) error {
+	for _, e := range events {
+		payload, err := e.Payload()
+		if err != nil {
+			return err
+		}
+		var topics []string
+		switch d := payload.(type) {
+		case *models.NameWritten:
+			topics = append(topics, models.TopicPersonCreated)
+			if d.Cause != nil {
+				topics = append(topics, models.TopicCauseAdded)
+			}
+			if d.Details != nil {
+				topics = append(topics, models.TopicDetailsAdded)
+			}
+		case *models.CauseSpecified:
+			if d.Cause != nil {
+				topics = append(topics, models.TopicCauseAdded)
+			}
+		case *models.DetailsSpecified:
+			if d.Details != nil {
+				topics = append(topics, models.TopicDetailsAdded)
+			}
+		case *models.Died:
+			topics = append(topics, models.TopicPersonDied)
+		}
+		for _, topic := range topics {
+			if err := enqueueOutbox(tx, topic, person.ID, person.ToPersonNotificationDto(), e.OccurredAt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FindAllAsOf devuelve las personas tal como estaban en el instante at,
+// según la historia temporal
+func (p *PeopleRepository) FindAllAsOf(at time.Time) ([]*models.Person, error) {
+	var versions []*models.PersonVersion
+	err := asOf(p.db, at).Order("person_id").Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	people := make([]*models.Person, 0, len(versions))
+	for _, v := range versions {
+		people = append(people, v.ToPerson())
+	}
+	return people, nil
+}
+
+// EachAsOf recorre con un cursor las personas tal como estaban en el
+// instante at, en orden de id, sin cargarlas todas en memoria
+func (p *PeopleRepository) EachAsOf(at time.Time, fn func(person *models.Person) error) error {
+	rows, err := asOf(p.db, at).Order("person_id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version models.PersonVersion
+		if err := p.db.ScanRows(rows, &version); err != nil {
+			return err
+		}
+		if err := fn(version.ToPerson()); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FindByIdAsOf devuelve la persona tal como estaba en el instante at; nil si
+// todavía no existía o ya estaba borrada
+func (p *PeopleRepository) FindByIdAsOf(id int, at time.Time) (*models.Person, error) {
+	var version models.PersonVersion
+	err := asOf(p.db, at).Where("person_id = ?", id).First(&version).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return version.ToPerson(), nil
+}
+
+// BackfillHistory abre la fila vigente de las personas sin historia
+// (anteriores a ella), válida desde su última escritura. Devuelve cuántas
+func (p *PeopleRepository) BackfillHistory() (int, error) {
+	var people []*models.Person
+	err := p.db.
+		Where("NOT EXISTS (SELECT 1 FROM person_versions WHERE person_versions.person_id = people.id)").
+		Order("id").
+		Find(&people).Error
+	if err != nil {
+		return 0, err
+	}
+	for _, person := range people {
+		if err := p.db.Create(models.NewPersonVersion(person, person.UpdatedAt)).Error; err != nil {
+			return 0, err
+		}
+	}
+	return len(people), nil
+}
+
+func asOf(db *gorm.DB, at time.Time) *gorm.DB {
+	return db.Model(&models.PersonVersion{}).
+		Where("valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)", at, at)
+}
+
+// recordHistory cierra en at la fila vigente de la persona y, si no está
+// borrada, abre otra con su estado actual. Lo llaman las escrituras de
+// people en su transacción
+func recordHistory(tx *gorm.DB, person *models.Person, at time.Time) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	err := db.Model(&models.PersonVersion{}).
+		Where("person_id = ? AND valid_to IS NULL", person.ID).
+		Update("valid_to", at).Error
+	if err != nil {
+		return err
+	}
+	if person.DeletedAt.Valid {
+		return nil
+	}
+	return db.Create(models.NewPersonVersion(person, at)).Error
+}
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"backend-avanzada/models"
+	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -18,6 +20,22 @@ func NewPeopleRepository(db *gorm.DB) *PeopleRepository {
 	}
 }
 
+// WithContext devuelve una copia del repositorio cuyas consultas usan ctx
+// (trazas, cancelación)
+func (p *PeopleRepository) WithContext(ctx context.Context) *PeopleRepository {
+	return &PeopleRepository{
+		db: p.db.WithContext(ctx),
+	}
+}
+
+// Unscoped devuelve una copia del repositorio que también ve las personas
+// borradas
+func (p *PeopleRepository) Unscoped() *PeopleRepository {
+	return &PeopleRepository{
+		db: p.db.Unscoped(),
+	}
+}
+
 func (p *PeopleRepository) FindAll() ([]*models.Person, error) {
 	var people []*models.Person
 	err := p.db.Find(&people).Error
@@ -27,11 +45,45 @@ func (p *PeopleRepository) FindAll() ([]*models.Person, error) {
 	return people, nil
 }
 
+// Save escribe el nombre de una persona nueva, o guarda como eventos lo que
+// cambió si ya existe
 func (p *PeopleRepository) Save(data *models.Person) (*models.Person, error) {
-	err := p.db.Save(data).Error
+	if data.ID != 0 {
+		person, err := p.change(data.ID, nil, func(current *models.Person) ([]*models.DomainEvent, error) {
+			return diffEvents(current, data)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if person == nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+		*data = *person
+		return data, nil
+	}
+
+	now := eventTime()
+	written, err := models.NewDomainEvent(models.EventTypeNameWritten, &models.NameWritten{
+		Name:      data.Name,
+		Age:       data.Age,
+		PhotoPath: data.PhotoPath,
+		Cause:     data.Cause,
+		Details:   data.Details,
+	}, now)
 	if err != nil {
 		return nil, err
 	}
+	events := []*models.DomainEvent{written}
+	if data.DeathTime != nil {
+		died, err := models.NewDomainEvent(models.EventTypeDied, &models.Died{At: data.DeathTime.Truncate(time.Microsecond)}, now)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, died)
+	}
+	if err := p.create(data, events); err != nil {
+		return nil, err
+	}
 	return data, nil
 }
 
@@ -48,49 +100,392 @@ func (p *PeopleRepository) FindById(id int) (*models.Person, error) {
 }
 
 func (p *PeopleRepository) Delete(data *models.Person) error {
-	err := p.db.Delete(data).Error
+	_, err := p.change(data.ID, nil, emit(models.EventTypePersonDeleted, &models.PersonDeleted{}))
+	return err
+}
+
+// Update guarda como eventos solo las columnas indicadas, sin pisar las que
+// haya cambiado una tarea de muerte mientras tanto
+func (p *PeopleRepository) Update(id uint, updates map[string]interface{}) error {
+	_, err := p.change(id, nil, func(current *models.Person) ([]*models.DomainEvent, error) {
+		return updateEvents(current, updates)
+	})
+	return err
+}
+
+// UpdateIfVersion actualiza solo si la persona sigue en version; devuelve
+// false si otra escritura se adelantó (bloqueo optimista)
+func (p *PeopleRepository) UpdateIfVersion(id, version uint, updates map[string]interface{}) (bool, error) {
+	person, err := p.change(id, &version, func(current *models.Person) ([]*models.DomainEvent, error) {
+		return updateEvents(current, updates)
+	})
+	return person != nil, err
+}
+
+// DeleteIfVersion borra la persona solo si sigue en version
+func (p *PeopleRepository) DeleteIfVersion(id, version uint) (bool, error) {
+	person, err := p.change(id, &version, emit(models.EventTypePersonDeleted, &models.PersonDeleted{}))
+	return person != nil, err
+}
+
+// ReplacePhoto cambia la foto solo si la persona sigue en version;
+// devuelve false si otra escritura se adelantó
+func (p *PeopleRepository) ReplacePhoto(id, version uint, newPath string) (bool, error) {
+	return p.UpdateIfVersion(id, version, map[string]interface{}{"photo_path": newPath})
+}
+
+// MarkHeartAttack asigna la causa y marca la hora de muerte. Indica si la
+// muerte se aplicó: false si la persona no existe o está borrada
+func (p *PeopleRepository) MarkHeartAttack(id uint) (bool, error) {
+	cause := "ataque al corazón"
+	person, err := p.change(id, nil, emit(models.EventTypeDied, &models.Died{At: eventTime(), Cause: &cause}))
+	return person != nil, err
+}
+
+// Añade la causa sin marcar la muerte; gorm.ErrRecordNotFound si la persona
+// no existe o está borrada
+func (p *PeopleRepository) AddCause(id uint, cause string) error {
+	person, err := p.change(id, nil, emit(models.EventTypeCauseSpecified, &models.CauseSpecified{Cause: &cause}))
+	if err == nil && person == nil {
+		return gorm.ErrRecordNotFound
+	}
+	return err
+}
+
+// Añade los detalles sin marcar la muerte; gorm.ErrRecordNotFound si la
+// persona no existe o está borrada
+func (p *PeopleRepository) AddDetails(id uint, details string) error {
+	person, err := p.change(id, nil, emit(models.EventTypeDetailsSpecified, &models.DetailsSpecified{Details: &details}))
+	if err == nil && person == nil {
+		return gorm.ErrRecordNotFound
+	}
+	return err
+}
+
+// Marca la muerte definitiva (está en cola tras causa o detalles). Indica
+// si se aplicó, como MarkHeartAttack
+func (p *PeopleRepository) MarkDeath(id uint) (bool, error) {
+	person, err := p.change(id, nil, emit(models.EventTypeDied, &models.Died{At: eventTime()}))
+	return person != nil, err
+}
+
+// ScheduleDeath deja constancia en el stream de que la muerte de kind quedó
+// programada para at. No cambia la fila de people
+func (p *PeopleRepository) ScheduleDeath(id uint, kind string, at time.Time) error {
+	_, err := p.change(id, nil, emit(models.EventTypeDeathScheduled, &models.DeathScheduled{Kind: kind, At: at.Truncate(time.Microsecond)}))
+	return err
+}
+
+// maxWriteAttempts limita los reintentos de una escritura sin versión que
+// compite con otra sobre la misma persona
+const maxWriteAttempts = 3
+
+// change lee la persona y, en una transacción, añade a su stream los
+// eventos que decide y los que arrastran a sus kills, proyecta el resultado
+// en people y kills, encola en el outbox su muerte y lo audita. Devuelve
+// la persona proyectada, o nil si no existe, está borrada o, con version,
+// otra escritura se adelantó
+func (p *PeopleRepository) change(id uint, version *uint, decide func(current *models.Person) ([]*models.DomainEvent, error)) (*models.Person, error) {
+	return p.changeStream(id, version, false, decide)
+}
+
+// changeStream es change; con deleted también escribe sobre personas
+// borradas (restaurarlas)
+func (p *PeopleRepository) changeStream(id uint, version *uint, deleted bool, decide func(current *models.Person) ([]*models.DomainEvent, error)) (*models.Person, error) {
+	for attempt := 1; ; attempt++ {
+		var projected *models.Person
+		err := p.db.Transaction(func(tx *gorm.DB) error {
+			current, err := findPersonUnscoped(tx, id)
+			if err != nil || current == nil || (current.DeletedAt.Valid && !deleted) {
+				return err
+			}
+			if version != nil && current.Version != *version {
+				return nil
+			}
+			events, err := decide(current)
+			if err != nil {
+				return err
+			}
+			next := *current
+			if len(events) == 0 {
+				projected = &next
+				return nil
+			}
+			if events, err = cascadeEvents(tx, current, events); err != nil {
+				return err
+			}
+			for _, e := range events {
+				e.StreamID = id
+				if err := next.Apply(e); err != nil {
+					return err
+				}
+			}
+			// La guarda de version serializa las escrituras sobre la persona
+			// aunque los eventos no cambien la fila
+			result := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&models.Person{}).
+				Where("id = ? AND version = ?", id, current.Version).
+				UpdateColumns(personColumns(&next))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errConcurrentWrite
+			}
+			if err := appendEvents(tx, id, events); err != nil {
+				return err
+			}
+			if err := projectKills(tx, events); err != nil {
+				return err
+			}
+			if err := notifyEvents(tx, &next, events); err != nil {
+				return err
+			}
+			if next.Version != current.Version || next.DeletedAt != current.DeletedAt {
+				if err := recordHistory(tx, &next, changedAt(&next)); err != nil {
+					return err
+				}
+			}
+			projected = &next
+			return auditPerson(tx, id, current)
+		})
+		if errors.Is(err, errConcurrentWrite) {
+			if version != nil {
+				return nil, nil
+			}
+			if attempt < maxWriteAttempts {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return projected, nil
+	}
+}
+
+// create inserta la persona que resulta de aplicar events a data.ID y abre
+// su stream con ellos
+func (p *PeopleRepository) create(data *models.Person, events []*models.DomainEvent) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		person := &models.Person{}
+		person.ID = data.ID
+		for _, e := range events {
+			if err := person.Apply(e); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(person).Error; err != nil {
+			return err
+		}
+		if err := appendEvents(tx, person.ID, events); err != nil {
+			return err
+		}
+		if err := notifyEvents(tx, person, events); err != nil {
+			return err
+		}
+		if err := recordHistory(tx, person, changedAt(person)); err != nil {
+			return err
+		}
+		*data = *person
+		return auditPerson(tx, person.ID, nil)
+	})
+}
+
+// changedAt es el instante de la última escritura de la persona: su
+// borrado o, si sigue viva en people, su updated_at
+func changedAt(p *models.Person) time.Time {
+	if p.DeletedAt.Valid {
+		return p.DeletedAt.Time
+	}
+	return p.UpdatedAt
+}
+
+// emit devuelve una decisión que siempre produce un único evento
+func emit(eventType string, payload any) func(*models.Person) ([]*models.DomainEvent, error) {
+	return func(*models.Person) ([]*models.DomainEvent, error) {
+		event, err := models.NewDomainEvent(eventType, payload, eventTime())
+		if err != nil {
+			return nil, err
+		}
+		return []*models.DomainEvent{event}, nil
+	}
+}
+
+// updateEvents traduce un mapa de columnas a los eventos que lo producen
+func updateEvents(current *models.Person, updates map[string]interface{}) ([]*models.DomainEvent, error) {
+	target := *current
+	for column, value := range updates {
+		var ok bool
+		switch column {
+		case "name":
+			target.Name, ok = value.(string)
+		case "age":
+			target.Age, ok = value.(int)
+		case "photo_path":
+			target.PhotoPath, ok = value.(string)
+		case "cause":
+			target.Cause, ok = optionalString(value)
+		case "details":
+			target.Details, ok = optionalString(value)
+		default:
+			return nil, fmt.Errorf("column %q cannot be updated", column)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for column %q", value, column)
+		}
+	}
+	return diffEvents(current, &target)
+}
+
+func optionalString(value interface{}) (*string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, true
+	case string:
+		return &v, true
+	case *string:
+		return v, true
+	}
+	return nil, false
+}
+
+// diffEvents devuelve los eventos que llevan de current a target; ninguno
+// si no hay diferencias
+func diffEvents(current, target *models.Person) ([]*models.DomainEvent, error) {
+	now := eventTime()
+	var types []string
+	var payloads []any
+	add := func(eventType string, payload any) {
+		types = append(types, eventType)
+		payloads = append(payloads, payload)
+	}
+
+	edited := &models.PersonEdited{}
+	if target.Name != current.Name {
+		edited.Name = &target.Name
+	}
+	if target.Age != current.Age {
+		edited.Age = &target.Age
+	}
+	if edited.Name != nil || edited.Age != nil {
+		add(models.EventTypePersonEdited, edited)
+	}
+	if target.PhotoPath != current.PhotoPath {
+		add(models.EventTypePhotoReplaced, &models.PhotoReplaced{PhotoPath: target.PhotoPath})
+	}
+	if !sameString(target.Cause, current.Cause) {
+		add(models.EventTypeCauseSpecified, &models.CauseSpecified{Cause: target.Cause})
+	}
+	if !sameString(target.Details, current.Details) {
+		add(models.EventTypeDetailsSpecified, &models.DetailsSpecified{Details: target.Details})
+	}
+	if target.DeathTime != nil && (current.DeathTime == nil || !target.DeathTime.Equal(*current.DeathTime)) {
+		add(models.EventTypeDied, &models.Died{At: target.DeathTime.Truncate(time.Microsecond)})
+	}
+
+	events := make([]*models.DomainEvent, 0, len(types))
+	for i, eventType := range types {
+		event, err := models.NewDomainEvent(eventType, payloads[i], now)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func sameString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// personColumns son las columnas de la proyección en people
+func personColumns(p *models.Person) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       p.Name,
+		"age":        p.Age,
+		"photo_path": p.PhotoPath,
+		"cause":      p.Cause,
+		"details":    p.Details,
+		"death_time": p.DeathTime,
+		"version":    p.Version,
+		"updated_at": p.UpdatedAt,
+		"deleted_at": p.DeletedAt,
+	}
+}
+
+// auditPerson registra la diferencia entre before y la persona actual: alta
+// si before es nil, borrado si ahora tiene deleted_at y cambio si no
+func auditPerson(tx *gorm.DB, id uint, before *models.Person) error {
+	after, err := findPersonUnscoped(tx, id)
 	if err != nil {
 		return err
 	}
-	return nil
+	action := models.AuditUpdate
+	switch {
+	case before == nil:
+		action = models.AuditCreate
+	case after == nil || (after.DeletedAt.Valid && !before.DeletedAt.Valid):
+		action = models.AuditDelete
+	}
+	return recordAudit(tx, action, models.AuditEntityPerson, id, personAuditFields(before), personAuditFields(after))
 }
 
-// MarkHeartAttack asigna la causa y marca la hora de muerte
-func (p *PeopleRepository) MarkHeartAttack(id uint) error {
-	now := time.Now()
-	updates := map[string]interface{}{
-		"cause":      "ataque al corazón",
-		"death_time": now,
+// findPersonUnscoped lee la persona aunque esté borrada; nil si no existe
+func findPersonUnscoped(tx *gorm.DB, id uint) (*models.Person, error) {
+	var person models.Person
+	err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Where("id = ?", id).First(&person).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
-	return p.db.Model(&models.Person{}).
-		Where("id = ?", id).
-		Updates(updates).Error
+	return &person, nil
 }
 
-// Añade la causa sin marcar la muerte
-func (p *PeopleRepository) AddCause(id uint, cause string) error {
-	return p.db.Model(&models.Person{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"cause": cause,
-		}).Error
+// Each recorre todas las personas por id leyendo del cursor de la base de
+// datos, sin cargarlas todas en memoria; se detiene si fn devuelve error
+func (p *PeopleRepository) Each(fn func(person *models.Person) error) error {
+	rows, err := p.db.Model(&models.Person{}).Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var person models.Person
+		if err := p.db.ScanRows(rows, &person); err != nil {
+			return err
+		}
+		if err := fn(&person); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
-// Añade los detalles sin marcar la muerte
-func (p *PeopleRepository) AddDetails(id uint, details string) error {
-	return p.db.Model(&models.Person{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"details": details,
-		}).Error
-}
-
-// Marca la muerte definitiva (está en cola tras causa o detalles)
-func (p *PeopleRepository) MarkDeath(id uint) error {
-	now := time.Now()
-	return p.db.Model(&models.Person{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"death_time": now,
-		}).Error
+// Insert crea la persona conservando su ID, fechas y versión; lo usa la
+// restauración de backups. Su stream empieza con PersonImported
+func (p *PeopleRepository) Insert(data *models.Person) error {
+	event, err := models.NewDomainEvent(models.EventTypePersonImported, importedPerson(data), eventTime())
+	if err != nil {
+		return err
+	}
+	return p.create(data, []*models.DomainEvent{event})
+}
+
+// CountAll cuenta las personas, incluidas las borradas, cuyos IDs siguen
+// ocupados
+func (p *PeopleRepository) CountAll() (int64, error) {
+	var count int64
+	err := p.db.Unscoped().Model(&models.Person{}).Count(&count).Error
+	return count, err
+}
+
+// ResetIDSequence alinea la secuencia de IDs con el mayor ID guardado tras
+// insertar filas con ID explícito. SQLite lo hace solo
+func (p *PeopleRepository) ResetIDSequence() error {
+	return resetIDSequence(p.db, "people")
 }
@@ -1,11 +1,13 @@
 package repository_test
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"backend-avanzada/audit"
 	"backend-avanzada/models"
 	"backend-avanzada/repository"
 
@@ -14,6 +16,10 @@ import (
 )
 
 func setupRepo(t *testing.T) *repository.PeopleRepository {
+	return repository.NewPeopleRepository(setupDB(t))
+}
+
+func setupDB(t *testing.T) *gorm.DB {
 	dsn := fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=5432 sslmode=disable",
 		os.Getenv("POSTGRES_HOST"),
@@ -27,13 +33,13 @@ func setupRepo(t *testing.T) *repository.PeopleRepository {
 		t.Fatalf("failed to connect to PostgreSQL: %v", err)
 	}
 
-	// Limpiar tabla antes del test
-	_ = db.Migrator().DropTable(&models.Person{})
-	if err := db.AutoMigrate(&models.Person{}); err != nil {
+	// Limpiar tablas antes del test
+	_ = db.Migrator().DropTable(&models.Person{}, &models.Kill{}, &models.AuditEntry{}, &models.DomainEvent{}, &models.PersonVersion{}, &models.PersonEvent{}, &models.OutboxMessage{}, &models.OutboxDelivery{})
+	if err := db.AutoMigrate(&models.Person{}, &models.Kill{}, &models.AuditEntry{}, &models.DomainEvent{}, &models.PersonVersion{}, &models.PersonEvent{}, &models.OutboxMessage{}, &models.OutboxDelivery{}); err != nil {
 		t.Fatalf("migration error: %v", err)
 	}
 
-	return repository.NewPeopleRepository(db)
+	return db
 }
 
 func TestAddCauseAndMarkDeath(t *testing.T) {
@@ -61,7 +67,7 @@ func TestAddCauseAndMarkDeath(t *testing.T) {
 	}
 
 	// 3) Probamos MarkDeath
-	if err := repo.MarkDeath(saved.ID); err != nil {
+	if _, err := repo.MarkDeath(saved.ID); err != nil {
 		t.Fatalf("MarkDeath() error: %v", err)
 	}
 	p2, _ := repo.FindById(int(saved.ID))
@@ -69,3 +75,250 @@ func TestAddCauseAndMarkDeath(t *testing.T) {
 		t.Errorf("MarkDeath: DeathTime no establecido correctamente, got %v", p2.DeathTime)
 	}
 }
+
+func TestUpdateIfVersion(t *testing.T) {
+	repo := setupRepo(t)
+
+	saved, err := repo.Save(&models.Person{Name: "Near", Age: 20, PhotoPath: "/static/near.jpg", Version: 1})
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	// Cada escritura sube la versión
+	if err := repo.AddCause(saved.ID, "Accidente"); err != nil {
+		t.Fatalf("AddCause() error: %v", err)
+	}
+	p1, _ := repo.FindById(int(saved.ID))
+	if p1.Version != 2 {
+		t.Fatalf("AddCause: esperé version 2, obtuve %d", p1.Version)
+	}
+
+	// Con la versión antigua no se actualiza
+	ok, err := repo.UpdateIfVersion(saved.ID, 1, map[string]interface{}{"name": "Nate"})
+	if err != nil || ok {
+		t.Fatalf("UpdateIfVersion con versión antigua: ok=%v err=%v", ok, err)
+	}
+	ok, err = repo.UpdateIfVersion(saved.ID, 2, map[string]interface{}{"name": "Nate"})
+	if err != nil || !ok {
+		t.Fatalf("UpdateIfVersion con versión actual: ok=%v err=%v", ok, err)
+	}
+	p2, _ := repo.FindById(int(saved.ID))
+	if p2.Name != "Nate" || p2.Version != 3 {
+		t.Errorf("esperé Nate en version 3, obtuve %q en %d", p2.Name, p2.Version)
+	}
+}
+
+func TestWritesAreAudited(t *testing.T) {
+	db := setupDB(t)
+	repo := repository.NewPeopleRepository(db)
+	ctx := audit.WithActor(context.Background(), "ryuk")
+
+	saved, err := repo.WithContext(ctx).Save(&models.Person{Name: "Mello", Age: 19, PhotoPath: "/static/mello.jpg", Version: 1})
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if err := repo.WithContext(ctx).AddCause(saved.ID, "Explosión"); err != nil {
+		t.Fatalf("AddCause() error: %v", err)
+	}
+	// Una actualización sin versión vigente no deja rastro
+	if _, err := repo.UpdateIfVersion(saved.ID, 1, map[string]interface{}{"name": "Mihael"}); err != nil {
+		t.Fatalf("UpdateIfVersion() error: %v", err)
+	}
+
+	entries, err := repository.NewAuditRepository(db).List(repository.AuditFilter{EntityType: models.AuditEntityPerson, EntityID: saved.ID})
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("esperé 2 entradas, obtuve %d", len(entries))
+	}
+	update, create := entries[0], entries[1]
+	if create.Action != models.AuditCreate || update.Action != models.AuditUpdate || update.Actor != "ryuk" {
+		t.Errorf("entradas inesperadas: %+v %+v", create, update)
+	}
+	if update.Changes != `{"cause":{"before":null,"after":"Explosión"}}` {
+		t.Errorf("changes = %s", update.Changes)
+	}
+}
+
+func TestReplayReconstructsProjection(t *testing.T) {
+	db := setupDB(t)
+	repo := repository.NewPeopleRepository(db)
+	store := repository.NewEventStore(db)
+
+	saved, err := repo.Save(&models.Person{Name: "L", Age: 25, PhotoPath: "/static/l.jpg", Version: 1})
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	id := saved.ID
+	if err := repo.AddCause(id, "Paro cardíaco"); err != nil {
+		t.Fatalf("AddCause() error: %v", err)
+	}
+	if err := repo.ScheduleDeath(id, "death", time.Now().Add(40*time.Second)); err != nil {
+		t.Fatalf("ScheduleDeath() error: %v", err)
+	}
+	if ok, err := repo.UpdateIfVersion(id, 2, map[string]interface{}{"name": "L Lawliet", "age": 24, "cause": nil}); err != nil || !ok {
+		t.Fatalf("UpdateIfVersion() ok=%v err=%v", ok, err)
+	}
+	if err := repo.AddDetails(id, "Tras comer pastel"); err != nil {
+		t.Fatalf("AddDetails() error: %v", err)
+	}
+	asOf := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if _, err := repository.NewKillRepository(db).Save(&models.Kill{PersonId: id, Description: "Cuaderno"}); err != nil {
+		t.Fatalf("Kill Save() error: %v", err)
+	}
+	if _, err := repo.MarkHeartAttack(id); err != nil {
+		t.Fatalf("MarkHeartAttack() error: %v", err)
+	}
+	if err := repo.Delete(&models.Person{Model: gorm.Model{ID: id}}); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+
+	var projected models.Person
+	if err := db.Unscoped().First(&projected, id).Error; err != nil {
+		t.Fatalf("First() error: %v", err)
+	}
+	replayed, kills, err := store.Replay(id, nil)
+	if err != nil {
+		t.Fatalf("Replay() error: %v", err)
+	}
+	if diff := personDiff(&projected, replayed); diff != "" {
+		t.Errorf("el replay no coincide con people: %s", diff)
+	}
+	if len(kills) != 1 || kills[0].Description != "Cuaderno" || kills[0].PersonId != id {
+		t.Errorf("kills del replay inesperadas: %+v", kills)
+	}
+
+	// Hasta asOf la persona seguía viva, con detalles y sin causa. Cada
+	// evento sube la versión: el PATCH de nombre, edad y causa son dos
+	past, kills, err := store.Replay(id, &asOf)
+	if err != nil {
+		t.Fatalf("Replay(asOf) error: %v", err)
+	}
+	if past.DeathTime != nil || past.Cause != nil || past.Details == nil || past.Name != "L Lawliet" || past.Version != 5 || len(kills) != 0 {
+		t.Errorf("estado en asOf inesperado: %+v, %d kills", past, len(kills))
+	}
+}
+
+func TestHistoryAsOf(t *testing.T) {
+	repo := setupRepo(t)
+
+	saved, err := repo.Save(&models.Person{Name: "Misa", Age: 19, PhotoPath: "/static/misa.jpg", Version: 1})
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if err := repo.ScheduleDeath(saved.ID, "death", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("ScheduleDeath() error: %v", err)
+	}
+	created := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if _, err := repo.MarkDeath(saved.ID); err != nil {
+		t.Fatalf("MarkDeath() error: %v", err)
+	}
+	dead := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	if err := repo.Delete(saved); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+
+	// La muerte programada no cambia la fila y no abre otra versión
+	if p, _ := repo.FindByIdAsOf(int(saved.ID), created); p == nil || p.DeathTime != nil || p.Version != 1 {
+		t.Errorf("tras el alta: %+v", p)
+	}
+	if p, _ := repo.FindByIdAsOf(int(saved.ID), dead); p == nil || p.DeathTime == nil || p.Version != 2 {
+		t.Errorf("tras la muerte: %+v", p)
+	}
+	if p, _ := repo.FindByIdAsOf(int(saved.ID), time.Now()); p != nil {
+		t.Errorf("tras el borrado: %+v", p)
+	}
+	if people, _ := repo.FindAllAsOf(saved.CreatedAt.Add(-time.Second)); len(people) != 0 {
+		t.Errorf("antes del alta: %d personas", len(people))
+	}
+}
+
+// personDiff compara las columnas de dos personas; vacío si coinciden
+func TestUnitOfWork(t *testing.T) {
+	db := setupDB(t)
+	uow := repository.NewUnitOfWork(db)
+	var committed, rolledBack bool
+
+	// Un fallo después de escribir en dos repositorios deshace ambos y solo
+	// ejecuta las compensaciones
+	failure := fmt.Errorf("boom")
+	err := uow.Do(func(tx *repository.Tx) error {
+		tx.OnRollback(func() { rolledBack = true })
+		tx.AfterCommit(func() { committed = true })
+		person, err := tx.People.Save(&models.Person{Name: "Misa", Age: 20})
+		if err != nil {
+			return err
+		}
+		if err := tx.Events.Record(person.ID, models.EventNameWritten, nil); err != nil {
+			return err
+		}
+		// La muerte encola su notificación en la misma transacción
+		if _, err := tx.People.MarkDeath(person.ID); err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure || committed || !rolledBack {
+		t.Fatalf("Do() = %v, committed=%v, rolledBack=%v", err, committed, rolledBack)
+	}
+	for _, model := range []any{&models.Person{}, &models.PersonEvent{}, &models.DomainEvent{}, &models.AuditEntry{}, &models.OutboxMessage{}} {
+		var count int64
+		db.Unscoped().Model(model).Count(&count)
+		if count != 0 {
+			t.Errorf("%T: quedan %d filas tras el rollback", model, count)
+		}
+	}
+
+	// Tras el commit se ejecuta lo diferido y ya se ve lo escrito
+	rolledBack = false
+	var id uint
+	err = uow.Do(func(tx *repository.Tx) error {
+		tx.OnRollback(func() { rolledBack = true })
+		person, err := tx.People.Save(&models.Person{Name: "Rem", Age: 30})
+		if err != nil {
+			return err
+		}
+		id = person.ID
+		tx.AfterCommit(func() {
+			found, _ := repository.NewPeopleRepository(db).FindById(int(person.ID))
+			committed = found != nil
+		})
+		return tx.People.AddCause(person.ID, "ataque al corazón")
+	})
+	if err != nil || !committed || rolledBack {
+		t.Fatalf("Do() = %v, committed=%v, rolledBack=%v", err, committed, rolledBack)
+	}
+	person, _ := repository.NewPeopleRepository(db).FindById(int(id))
+	if person == nil || person.Cause == nil || person.Version != 2 {
+		t.Errorf("persona tras el commit: %+v", person)
+	}
+}
+
+func personDiff(a, b *models.Person) string {
+	switch {
+	case b == nil:
+		return "sin estado"
+	case a.ID != b.ID || a.Name != b.Name || a.Age != b.Age || a.PhotoPath != b.PhotoPath || a.Version != b.Version:
+		return fmt.Sprintf("%+v != %+v", a, b)
+	case !equalPtr(a.Cause, b.Cause) || !equalPtr(a.Details, b.Details):
+		return fmt.Sprintf("causa/detalles %v %v != %v %v", a.Cause, a.Details, b.Cause, b.Details)
+	case !a.CreatedAt.Equal(b.CreatedAt) || !a.UpdatedAt.Equal(b.UpdatedAt):
+		return fmt.Sprintf("fechas %v %v != %v %v", a.CreatedAt, a.UpdatedAt, b.CreatedAt, b.UpdatedAt)
+	case (a.DeathTime == nil) != (b.DeathTime == nil) || a.DeathTime != nil && !a.DeathTime.Equal(*b.DeathTime):
+		return fmt.Sprintf("muerte %v != %v", a.DeathTime, b.DeathTime)
+	case a.DeletedAt.Valid != b.DeletedAt.Valid || !a.DeletedAt.Time.Equal(b.DeletedAt.Time):
+		return fmt.Sprintf("borrado %v != %v", a.DeletedAt, b.DeletedAt)
+	}
+	return ""
+}
+
+func equalPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// resetIDSequence mueve la secuencia serial de table.id al máximo actual
+// para que el siguiente INSERT no choque con IDs insertados a mano
+func resetIDSequence(db *gorm.DB, table string) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return db.Exec(fmt.Sprintf(
+		"SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE((SELECT MAX(id) FROM %[1]s), 0) + 1, false)",
+		table,
+	)).Error
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TaskRepository struct {
+	db *gorm.DB
+}
+
+func NewTaskRepository(db *gorm.DB) *TaskRepository {
+	return &TaskRepository{
+		db: db,
+	}
+}
+
+// WithContext devuelve una copia del repositorio cuyas consultas usan ctx
+// (trazas, cancelación)
+func (t *TaskRepository) WithContext(ctx context.Context) *TaskRepository {
+	return &TaskRepository{
+		db: t.db.WithContext(ctx),
+	}
+}
+
+// Put guarda la tarea de la persona, sustituyendo la que tuviera
+func (t *TaskRepository) Put(task *models.ScheduledTask) error {
+	task.RunAt = task.RunAt.Truncate(time.Microsecond)
+	return t.db.Save(task).Error
+}
+
+// Delete borra la tarea de la persona si es de kind, o de cualquier tipo si
+// kind está vacío. Indica si había una que borrar
+func (t *TaskRepository) Delete(personID uint, kind string) (bool, error) {
+	query := t.db.Where("person_id = ?", personID)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	result := query.Delete(&models.ScheduledTask{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Complete borra la tarea de la persona que ya tocaba ejecutar en at. Si
+// mientras se ejecutaba otra la sustituyó para más tarde, esa se queda
+func (t *TaskRepository) Complete(personID uint, at time.Time) error {
+	return t.db.Where("person_id = ? AND run_at <= ?", personID, at).Delete(&models.ScheduledTask{}).Error
+}
+
+// FindAll devuelve las tareas pendientes, de la más próxima a la más lejana
+func (t *TaskRepository) FindAll() ([]*models.ScheduledTask, error) {
+	var tasks []*models.ScheduledTask
+	if err := t.db.Order("run_at, person_id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
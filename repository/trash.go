@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FindDeleted devuelve las personas en la papelera, de la borrada más
+// recientemente a la más antigua
+func (p *PeopleRepository) FindDeleted() ([]*models.Person, error) {
+	var people []*models.Person
+	err := p.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC, id").Find(&people).Error
+	if err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+// FindDeletedBefore devuelve las personas borradas antes de cutoff, las
+// que la retención de la papelera ya puede purgar
+func (p *PeopleRepository) FindDeletedBefore(cutoff time.Time) ([]*models.Person, error) {
+	var people []*models.Person
+	err := p.db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Order("id").Find(&people).Error
+	if err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+// Restore saca a la persona de la papelera con PersonRestored. Devuelve la
+// persona, sin cambios si no estaba borrada, o nil si no existe
+func (p *PeopleRepository) Restore(id uint) (*models.Person, error) {
+	return p.changeStream(id, nil, true, func(current *models.Person) ([]*models.DomainEvent, error) {
+		if !current.DeletedAt.Valid {
+			return nil, nil
+		}
+		return emit(models.EventTypePersonRestored, &models.PersonRestored{})(current)
+	})
+}
+
+// Purge borra para siempre a la persona y todo lo que cuelga de ella: sus
+// kills, su stream de eventos, su historia y su línea de tiempo. Es la única
+// escritura que borra eventos; el registro de auditoría conserva el borrado.
+// Devuelve la persona purgada o nil si no existía
+func (p *PeopleRepository) Purge(id uint) (*models.Person, error) {
+	var purged *models.Person
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		person, err := findPersonUnscoped(tx, id)
+		if err != nil || person == nil {
+			return err
+		}
+		// Una sesión nueva por consulta: Unscoped devuelve una instancia que
+		// acumularía las condiciones de las anteriores
+		db := func() *gorm.DB {
+			return tx.Session(&gorm.Session{NewDB: true}).Unscoped()
+		}
+
+		var kills []*models.Kill
+		if err := db().Where("person_id = ?", id).Find(&kills).Error; err != nil {
+			return err
+		}
+		if err := db().Where("person_id = ?", id).Delete(&models.Kill{}).Error; err != nil {
+			return err
+		}
+		for _, kill := range kills {
+			if err := auditKill(tx, kill.ID, kill); err != nil {
+				return err
+			}
+		}
+		if err := db().Where("stream_id = ?", id).Delete(&models.DomainEvent{}).Error; err != nil {
+			return err
+		}
+		if err := db().Where("person_id = ?", id).Delete(&models.PersonVersion{}).Error; err != nil {
+			return err
+		}
+		if err := db().Where("person_id = ?", id).Delete(&models.PersonEvent{}).Error; err != nil {
+			return err
+		}
+		if err := db().Where("id = ?", id).Delete(&models.Person{}).Error; err != nil {
+			return err
+		}
+		purged = person
+		return auditPerson(tx, id, person)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return purged, nil
+}
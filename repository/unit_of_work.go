@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork ejecuta las escrituras de varios repositorios en una sola
+// transacción de GORM. Lo que queda fuera de la base de datos (armar el
+// temporizador de una tarea, borrar ficheros) no se hace dentro: se registra
+// en la transacción y se ejecuta cuando se sabe si hubo commit. Las tareas
+// además se guardan dentro, en Tasks, para no perderlas si el proceso cae
+// antes de encolarlas
+type UnitOfWork struct {
+	db *gorm.DB
+}
+
+func NewUnitOfWork(db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{
+		db: db,
+	}
+}
+
+// WithContext devuelve una copia cuyas transacciones usan ctx (trazas,
+// cancelación, actor y request id)
+func (u *UnitOfWork) WithContext(ctx context.Context) *UnitOfWork {
+	return &UnitOfWork{
+		db: u.db.WithContext(ctx),
+	}
+}
+
+// Tx son los repositorios ligados a una transacción en curso. Sus
+// escrituras se confirman o se deshacen juntas; las propias transacciones
+// de cada repositorio pasan a ser savepoints de esta. Tasks guarda las
+// tareas que se encolan después del commit
+type Tx struct {
+	People *PeopleRepository
+	Kills  *KillRepository
+	Events *EventRepository
+	Tasks  *TaskRepository
+
+	afterCommit []func()
+	onRollback  []func()
+}
+
+// AfterCommit registra fn para ejecutarla solo si la transacción se
+// confirma, en el orden en que se registraron
+func (t *Tx) AfterCommit(fn func()) {
+	t.afterCommit = append(t.afterCommit, fn)
+}
+
+// OnRollback registra fn para deshacer un efecto ya hecho fuera de la base
+// de datos (una foto guardada) si la transacción no llega a confirmarse
+func (t *Tx) OnRollback(fn func()) {
+	t.onRollback = append(t.onRollback, fn)
+}
+
+// Do ejecuta fn en una transacción. Si fn devuelve error o el commit falla
+// se deshace todo y se ejecutan las funciones de OnRollback; si no, las de
+// AfterCommit. Devuelve el error de fn o del commit
+func (u *UnitOfWork) Do(fn func(tx *Tx) error) error {
+	t := &Tx{}
+	err := u.db.Transaction(func(db *gorm.DB) error {
+		t.People = &PeopleRepository{db: db}
+		t.Kills = &KillRepository{db: db}
+		t.Events = &EventRepository{db: db}
+		t.Tasks = &TaskRepository{db: db}
+		return fn(t)
+	})
+	if err != nil {
+		for _, undo := range t.onRollback {
+			undo()
+		}
+		return err
+	}
+	for _, after := range t.afterCommit {
+		after()
+	}
+	return nil
+}
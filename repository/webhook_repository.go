@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"backend-avanzada/models"
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db: db,
+	}
+}
+
+// WithContext devuelve una copia del repositorio cuyas consultas usan ctx
+// (trazas, cancelación)
+func (w *WebhookRepository) WithContext(ctx context.Context) *WebhookRepository {
+	return &WebhookRepository{
+		db: w.db.WithContext(ctx),
+	}
+}
+
+func (w *WebhookRepository) Save(data *models.Webhook) (*models.Webhook, error) {
+	if err := w.db.Create(data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (w *WebhookRepository) FindAll() ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	if err := w.db.Order("id").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (w *WebhookRepository) FindById(id int) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := w.db.Where("id = ?", id).First(&webhook).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// Delete borra la suscripción junto con su registro de entregas
+func (w *WebhookRepository) Delete(data *models.Webhook) error {
+	return w.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{NewDB: true}).Where("webhook_id = ?", data.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{NewDB: true}).Delete(data).Error
+	})
+}
+
+// Subscribed devuelve las suscripciones a topic
+func (w *WebhookRepository) Subscribed(topic string) ([]*models.Webhook, error) {
+	webhooks, err := w.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	var result []*models.Webhook
+	for _, webhook := range webhooks {
+		if webhook.Subscribed(topic) {
+			result = append(result, webhook)
+		}
+	}
+	return result, nil
+}
+
+// Attempts devuelve cuántas veces se intentó enviar el mensaje a la
+// suscripción y si alguna salió bien
+func (w *WebhookRepository) Attempts(webhookID, messageID uint) (int, bool, error) {
+	var deliveries []*models.WebhookDelivery
+	err := w.db.Where("webhook_id = ? AND message_id = ?", webhookID, messageID).Find(&deliveries).Error
+	if err != nil {
+		return 0, false, err
+	}
+	for _, d := range deliveries {
+		if d.Success {
+			return len(deliveries), true, nil
+		}
+	}
+	return len(deliveries), false, nil
+}
+
+// SaveHealth guarda los fallos seguidos de la suscripción y cuándo volver
+// a intentarlo
+func (w *WebhookRepository) SaveHealth(data *models.Webhook) error {
+	return w.db.Model(&models.Webhook{}).
+		Where("id = ?", data.ID).
+		Updates(map[string]interface{}{
+			"failures": data.Failures,
+			"retry_at": data.RetryAt,
+		}).Error
+}
+
+// LogDelivery añade un intento al registro de entregas
+func (w *WebhookRepository) LogDelivery(data *models.WebhookDelivery) error {
+	return w.db.Create(data).Error
+}
+
+// Deliveries devuelve hasta limit intentos de la suscripción, del más
+// reciente al más antiguo
+func (w *WebhookRepository) Deliveries(webhookID uint, limit int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	err := w.db.Where("webhook_id = ?", webhookID).Order("id DESC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"backend-avanzada/logger"
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// accessRoute es donde recordRoute deja la plantilla de la ruta para el
+// access log y las métricas, que envuelven al router entero y no la ven en
+// su contexto
+type accessRoute struct {
+	template string
+}
+
+type accessRouteKey struct{}
+
+// withAccessRoute devuelve la petición con un accessRoute en el contexto,
+// el que ya tuviera o uno nuevo con la ruta "unmatched" (404 y 405)
+func withAccessRoute(r *http.Request) (*http.Request, *accessRoute) {
+	if route, ok := r.Context().Value(accessRouteKey{}).(*accessRoute); ok {
+		return r, route
+	}
+	route := &accessRoute{template: unmatchedRoute}
+	return r.WithContext(context.WithValue(r.Context(), accessRouteKey{}, route)), route
+}
+
+// accessLog registra cada petición después de atenderla, con el status y el
+// tamaño reales de la respuesta. Envuelve al router entero para registrar
+// también los 404 y 405, que no pasan por los middlewares de mux
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := newResponseWriter(w)
+		r, route := withAccessRoute(r)
+		next.ServeHTTP(rw, r)
+
+		user, _, _ := r.BasicAuth()
+		s.logger.Access(r.Context(), s.Config.AccessLogFormat, &logger.AccessEntry{
+			Time:      start,
+			ClientIP:  clientIP(r),
+			User:      user,
+			Method:    r.Method,
+			URI:       r.RequestURI,
+			Proto:     r.Proto,
+			Route:     route.template,
+			Status:    rw.status,
+			Size:      rw.size,
+			Latency:   time.Since(start),
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+		})
+	})
+}
+
+// recordRoute anota en el contexto la plantilla de la ruta que mux eligió
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(accessRouteKey{}).(*accessRoute); ok {
+			route.template = routeTemplate(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
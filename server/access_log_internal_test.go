@@ -0,0 +1,59 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend-avanzada/config"
+	"backend-avanzada/logger"
+)
+
+func TestAccessLogCoversUnmatchedRoutes(t *testing.T) {
+	var buf bytes.Buffer
+	s := &Server{Config: &config.Config{AccessLogFormat: logger.AccessJSON}, logger: logger.New(logger.FormatJSON, &buf)}
+	s.taskQueue = NewTaskQueue(s.logger)
+	s.metrics = NewMetrics(s.taskQueue)
+	if err := s.initTracing(); err != nil {
+		t.Fatal(err)
+	}
+	validator, err := newRequestValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.validator = validator
+	router := s.GetRouter()
+
+	cases := []struct {
+		method, path, route string
+		status              int
+	}{
+		{http.MethodGet, "/openapi.json", "/openapi.json", http.StatusOK},
+		{http.MethodGet, "/no-existe", "unmatched", http.StatusNotFound},
+		{http.MethodPost, "/healthz", "unmatched", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, nil))
+		if rec.Code != c.status {
+			t.Fatalf("%s %s: %d", c.method, c.path, rec.Code)
+		}
+		var access map[string]any
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+			var entry map[string]any
+			if json.Unmarshal(line, &entry) == nil && entry["msg"] == "access" {
+				access = entry
+			}
+		}
+		if access == nil {
+			t.Fatalf("%s %s sin access log: %s", c.method, c.path, buf.String())
+		}
+		if access["route"] != c.route || access["status"] != float64(c.status) || access["latency"] == nil ||
+			access["request_id"] != rec.Header().Get(logger.RequestIDHeader) {
+			t.Errorf("%s %s: %v", c.method, c.path, access)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"net/http"
+	"time"
+)
+
+// asOfParam lee ?as_of= (RFC 3339); nil si no viene
+func asOfParam(r *http.Request) (*time.Time, error) {
+	v := r.URL.Query().Get("as_of")
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, apiErrorf(CodeBadRequest, "as_of must be an RFC 3339 timestamp")
+	}
+	return &t, nil
+}
+
+// listPeople devuelve las personas actuales o, con ?as_of=, las que había
+// en ese instante según la historia
+func (s *Server) listPeople(r *http.Request) ([]*models.Person, error) {
+	at, err := asOfParam(r)
+	if err != nil {
+		return nil, err
+	}
+	repo := s.PeopleRepository.WithContext(r.Context())
+	if at != nil {
+		return repo.FindAllAsOf(*at)
+	}
+	return repo.FindAll()
+}
+
+// findPersonAsOf es findPerson respetando ?as_of=: la persona tal como
+// estaba en ese instante, o person_not_found si no existía
+func (s *Server) findPersonAsOf(r *http.Request, id int) (*models.Person, error) {
+	at, err := asOfParam(r)
+	if err != nil {
+		return nil, err
+	}
+	if at == nil {
+		return s.findPerson(r.Context(), id)
+	}
+	person, err := s.PeopleRepository.WithContext(r.Context()).FindByIdAsOf(id, *at)
+	if err != nil {
+		return nil, err
+	}
+	if person == nil {
+		return nil, apiErrorf(CodePersonNotFound, "person with id %d did not exist at %s", id, at.Format(time.RFC3339))
+	}
+	return person, nil
+}
@@ -0,0 +1,86 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getAsOf(t *testing.T, h http.Handler, path string, at time.Time) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path+"?as_of="+url.QueryEscape(at.Format(time.RFC3339Nano)), nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPeopleAsOf(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+	beforeCreate := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	created := createPersonAt(t, s, "/v2/people")
+	id := int(created["id"].(float64))
+	defer s.CancelTaskForTest(id)
+	path := fmt.Sprintf("/v2/people/%d", id)
+
+	time.Sleep(10 * time.Millisecond)
+	pending := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	req := httptest.NewRequest(http.MethodPost, path+"/cause", strings.NewReader(`{"cause":"accidente"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("cause: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Antes de la causa la persona no tenía causa; ahora sí
+	rec = getAsOf(t, router, path, pending)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("as_of: %d %s", rec.Code, rec.Body.String())
+	}
+	var past map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &past)
+	if past["cause"] != nil || past["status"] != "pending" {
+		t.Errorf("estado pasado inesperado: %v", past)
+	}
+	rec = getAsOf(t, router, path, time.Now())
+	var now map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &now)
+	if now["cause"] != "accidente" {
+		t.Errorf("estado actual inesperado: %v", now)
+	}
+
+	// Antes de escribir el nombre no existía
+	rec = getAsOf(t, router, "/v1/people", beforeCreate)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "null" {
+		t.Errorf("lista antes del alta: %d %s", rec.Code, rec.Body.String())
+	}
+	decodeProblem(t, getAsOf(t, router, path, beforeCreate), http.StatusNotFound)
+
+	// Tras el borrado ya no está, pero sigue en el pasado
+	req = httptest.NewRequest(http.MethodDelete, path, nil)
+	req.Header.Set("If-Match", currentETag(t, router, path))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+	decodeProblem(t, getAsOf(t, router, path, time.Now()), http.StatusNotFound)
+	rec = getAsOf(t, router, "/v2/people", pending)
+	var people []map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &people)
+	if len(people) != 1 || people[0]["id"] != float64(id) {
+		t.Errorf("lista en el pasado: %s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/people?as_of=ayer", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeValidation(t, rec)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/audit"
+	"backend-avanzada/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ActorHeader identifica a quien hace la petición en el registro de
+	// auditoría. No hay usuarios, así que es un valor declarado por el
+	// cliente sin autenticar: informativo, no prueba quién escribió
+	ActorHeader     = "X-Actor"
+	maxActorLen     = 128
+	defaultAuditMax = 100
+	maxAuditLimit   = 1000
+)
+
+// auditActor guarda en el contexto el actor de la petición: "admin" si trae
+// el token de administración, X-Actor si es válido y "anonymous" si no
+func (s *Server) auditActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(audit.WithActor(r.Context(), s.requestActor(r))))
+	})
+}
+
+func (s *Server) requestActor(r *http.Request) string {
+	if s.isAdmin(r) {
+		return audit.ActorAdmin
+	}
+	actor := strings.TrimSpace(r.Header.Get(ActorHeader))
+	if actor == "" || len(actor) > maxActorLen || strings.ContainsFunc(actor, func(c rune) bool { return c < ' ' || c == 0x7f }) {
+		return audit.ActorAnonymous
+	}
+	// Los valores reservados solo los asigna el servidor
+	if actor == audit.ActorAdmin || actor == audit.ActorSystem {
+		return audit.ActorAnonymous
+	}
+	return actor
+}
+
+// HandleAudit lista el registro de auditoría, de lo más reciente a lo más
+// antiguo, con filtros opcionales por actor, acción, entidad, petición y fecha
+func (s *Server) HandleAudit(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	filter := repository.AuditFilter{
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		EntityType: q.Get("entity_type"),
+		RequestID:  q.Get("request_id"),
+		Limit:      defaultAuditMax,
+	}
+	if v := q.Get("entity_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return apiErrorf(CodeBadRequest, "entity_id must be a positive integer")
+		}
+		filter.EntityID = uint(id)
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return apiErrorf(CodeBadRequest, "limit must be between 1 and %d", maxAuditLimit)
+		}
+		filter.Limit = limit
+	}
+	for name, dst := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return apiErrorf(CodeBadRequest, "%s must be an RFC 3339 timestamp", name)
+			}
+			*dst = &t
+		}
+	}
+
+	entries, err := s.AuditRepository.WithContext(r.Context()).List(filter)
+	if err != nil {
+		return err
+	}
+	result := make([]*api.AuditEntryDto, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, e.ToAuditEntryDto())
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
@@ -0,0 +1,85 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type auditEntry struct {
+	Actor      string                                 `json:"actor"`
+	Action     string                                 `json:"action"`
+	EntityType string                                 `json:"entity_type"`
+	RequestID  string                                 `json:"request_id"`
+	Changes    map[string]struct{ Before, After any } `json:"changes"`
+}
+
+func listAudit(t *testing.T, h http.Handler, query string) []auditEntry {
+	req := httptest.NewRequest(http.MethodGet, "/audit?"+query, nil)
+	req.Header.Set("Authorization", "Bearer secreto")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /audit?%s: %d %s", query, rec.Code, rec.Body.String())
+	}
+	var entries []auditEntry
+	json.Unmarshal(rec.Body.Bytes(), &entries)
+	return entries
+}
+
+func TestAuditRecordsWritesAndTasks(t *testing.T) {
+	s := createTestServer(t)
+	s.Config.AdminToken = "secreto"
+	router := s.GetRouter()
+	created := createPersonAt(t, s, "/v1/people")
+	id := int(created["person_id"].(float64))
+	defer s.CancelTaskForTest(id)
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/v1/people/%d/cause", id), strings.NewReader(`{"cause":"accidente"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Actor", "ryuk")
+	req.Header.Set("X-Request-ID", "req-cause")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("cause: %d %s", rec.Code, rec.Body.String())
+	}
+
+	people := listAudit(t, router, fmt.Sprintf("entity_type=person&entity_id=%d", id))
+	if len(people) != 2 || people[1].Action != "create" || people[1].Actor != "anonymous" {
+		t.Fatalf("entradas de persona inesperadas: %+v", people)
+	}
+	cause := people[0]
+	if cause.Action != "update" || cause.Actor != "ryuk" || cause.RequestID != "req-cause" || cause.Changes["cause"].After != "accidente" {
+		t.Errorf("entrada de la causa inesperada: %+v", cause)
+	}
+
+	// Programada al crear, cancelada y reprogramada al añadir la causa
+	var actions []string
+	for _, e := range listAudit(t, router, fmt.Sprintf("entity_type=task&entity_id=%d", id)) {
+		actions = append(actions, e.Action)
+	}
+	if strings.Join(actions, ",") != "schedule,cancel,schedule" {
+		t.Errorf("acciones de la tarea = %v", actions)
+	}
+
+	if entries := listAudit(t, router, "actor=ryuk&action=update"); len(entries) != 1 {
+		t.Errorf("filtro por actor y acción: %d entradas", len(entries))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/audit?since=ayer", nil)
+	req.Header.Set("Authorization", "Bearer secreto")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeValidation(t, rec)
+
+	// Sin el token de administración no se puede leer
+	req = httptest.NewRequest(http.MethodGet, "/audit", nil)
+	req.Header.Set("X-Actor", "admin")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusUnauthorized)
+}
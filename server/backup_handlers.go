@@ -0,0 +1,530 @@
+package server
+
+import (
+	"archive/zip"
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	backupFormat = "deathnote-backup"
+	// backupFormatVersion sube cuando cambia el contenido del archivo; restore
+	// solo acepta su propia versión
+	backupFormatVersion = 1
+	// maxBackupSize limita el ZIP de POST /admin/restore (1 GB)
+	maxBackupSize = 1 << 30
+
+	backupManifestFile = "manifest.json"
+	backupPeopleFile   = "people.jsonl"
+	backupKillsFile    = "kills.jsonl"
+	backupTasksFile    = "tasks.jsonl"
+	backupPhotosDir    = "photos/"
+)
+
+// backupPerson es una línea de people.jsonl; incluye las personas borradas
+// para que las kills que las referencian sigan siendo válidas
+type backupPerson struct {
+	ID        uint       `json:"id"`
+	Name      string     `json:"name"`
+	Age       int        `json:"age"`
+	PhotoURL  string     `json:"photo_url"`
+	Cause     *string    `json:"cause,omitempty"`
+	Details   *string    `json:"details,omitempty"`
+	DeathTime *time.Time `json:"death_time,omitempty"`
+	Version   uint       `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// backupKill es una línea de kills.jsonl; incluye las kills revocadas y las
+// borradas en cascada con su persona, que vuelven si se restaura la persona
+type backupKill struct {
+	ID          uint       `json:"id"`
+	PersonID    uint       `json:"person_id"`
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+// backupTask es una línea de tasks.jsonl: una tarea pendiente y el tiempo
+// que le quedaba cuando se hizo el backup
+type backupTask struct {
+	PersonID    int      `json:"person_id"`
+	Kind        TaskKind `json:"kind"`
+	RemainingMs int64    `json:"remaining_ms"`
+	Description string   `json:"description,omitempty"` // solo en kind "kill"
+}
+
+// requireAdmin protege las rutas de /admin con el Bearer de admin_token. Sin
+// token configurado las rutas están deshabilitadas
+func (s *Server) requireAdmin(h handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if s.Config.AdminToken == "" {
+			return apiErrorf(CodeForbidden, "admin endpoints are disabled, set admin_token to enable them")
+		}
+		if !s.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			return apiErrorf(CodeUnauthorized, "a valid admin bearer token is required")
+		}
+		return h(w, r)
+	}
+}
+
+// isAdmin indica si la petición trae el Bearer de admin_token
+func (s *Server) isAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.Config.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.AdminToken)) == 1
+}
+
+// HandleBackup envía un ZIP con personas, kills, tareas pendientes y fotos.
+// Se escribe mientras se lee la BD; si algo falla antes del primer byte se
+// responde con problem+json, después solo queda cortar la respuesta
+func (s *Server) HandleBackup(w http.ResponseWriter, r *http.Request) error {
+	now := time.Now()
+	tasks := s.taskQueue.Snapshot()
+
+	out := &startOnWrite{w: w, start: func() {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "deathnote-backup-"+now.Format("20060102-150405")+".zip"))
+		w.WriteHeader(http.StatusOK)
+	}}
+	archive := zip.NewWriter(out)
+	manifest := &api.BackupManifestDto{
+		Format:     backupFormat,
+		Version:    backupFormatVersion,
+		CreatedAt:  now.UTC().Format(time.RFC3339),
+		AppVersion: Version,
+	}
+
+	err := s.writeBackup(r, archive, manifest, tasks, now)
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		if out.started {
+			s.logger.ErrorContext(r.Context(), "backup aborted", "error", err)
+			return nil
+		}
+		return err
+	}
+	s.logger.InfoContext(r.Context(), "backup created",
+		"people", manifest.Counts.People, "kills", manifest.Counts.Kills,
+		"tasks", manifest.Counts.Tasks, "photos", manifest.Counts.Photos)
+	return nil
+}
+
+func (s *Server) writeBackup(r *http.Request, archive *zip.Writer, manifest *api.BackupManifestDto, tasks []PendingTask, now time.Time) error {
+	ctx := r.Context()
+
+	// 1) Personas, recordando qué fotos hay que copiar
+	var photos []string
+	seen := map[string]bool{}
+	people, err := archive.Create(backupPeopleFile)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(people)
+	err = s.PeopleRepository.WithContext(ctx).Unscoped().Each(func(p *models.Person) error {
+		line := &backupPerson{
+			ID:        p.ID,
+			Name:      p.Name,
+			Age:       p.Age,
+			PhotoURL:  p.PhotoPath,
+			Cause:     p.Cause,
+			Details:   p.Details,
+			DeathTime: p.DeathTime,
+			Version:   p.Version,
+			CreatedAt: p.CreatedAt,
+			UpdatedAt: p.UpdatedAt,
+		}
+		if p.DeletedAt.Valid {
+			line.DeletedAt = &p.DeletedAt.Time
+		}
+		if name := photoFileName(p.PhotoPath); name != "" && !seen[name] {
+			seen[name] = true
+			photos = append(photos, name)
+		}
+		manifest.Counts.People++
+		return encoder.Encode(line)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 2) Kills
+	kills, err := archive.Create(backupKillsFile)
+	if err != nil {
+		return err
+	}
+	encoder = json.NewEncoder(kills)
+	err = s.KillRepository.WithContext(ctx).Unscoped().Each(func(k *models.Kill) error {
+		manifest.Counts.Kills++
+		line := &backupKill{
+			ID:          k.ID,
+			PersonID:    k.PersonId,
+			Description: k.Description,
+			CreatedAt:   k.CreatedAt,
+			UpdatedAt:   k.UpdatedAt,
+		}
+		if k.DeletedAt.Valid {
+			line.DeletedAt = &k.DeletedAt.Time
+		}
+		return encoder.Encode(line)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 3) Tareas pendientes con el tiempo que les queda
+	taskFile, err := archive.Create(backupTasksFile)
+	if err != nil {
+		return err
+	}
+	encoder = json.NewEncoder(taskFile)
+	for _, t := range tasks {
+		line := &backupTask{
+			PersonID:    t.ID,
+			Kind:        t.Kind,
+			RemainingMs: max(t.RunAt.Sub(now).Milliseconds(), 0),
+		}
+		if t.Kind == TaskKill && t.Kill != nil {
+			line.Description = t.Kill.Description
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+		manifest.Counts.Tasks++
+	}
+
+	// 4) Fotos de uploads/; las que faltan se anotan en el manifiesto
+	for _, name := range photos {
+		copied, err := copyPhotoToBackup(archive, name)
+		if err != nil {
+			return err
+		}
+		if !copied {
+			s.logger.WarnContext(ctx, "photo missing from backup", "photo", name)
+			manifest.MissingPhotos = append(manifest.MissingPhotos, name)
+			continue
+		}
+		manifest.Counts.Photos++
+	}
+
+	// 5) El manifiesto va al final porque lleva los totales
+	manifestFile, err := archive.Create(backupManifestFile)
+	if err != nil {
+		return err
+	}
+	encoder = json.NewEncoder(manifestFile)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// copyPhotoToBackup copia uploads/name a photos/name; devuelve false si el
+// archivo ya no existe
+func copyPhotoToBackup(archive *zip.Writer, name string) (bool, error) {
+	f, err := os.Open(filepath.Join(uploadsDir, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	// Las imágenes ya vienen comprimidas, se guardan tal cual
+	dst, err := archive.CreateHeader(&zip.FileHeader{Name: backupPhotosDir + name, Method: zip.Store})
+	if err != nil {
+		return false, err
+	}
+	_, err = io.Copy(dst, f)
+	return err == nil, err
+}
+
+// HandleRestore carga un backup en una base de datos vacía: inserta personas
+// y kills con sus IDs originales, copia las fotos a uploads/ y vuelve a
+// programar las tareas pendientes con el tiempo que les quedaba, contado
+// desde ahora
+func (s *Server) HandleRestore(w http.ResponseWriter, r *http.Request) error {
+	// El ZIP se guarda en disco para no tenerlo entero en memoria
+	tmp, err := os.CreateTemp("", "deathnote-restore-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	size, err := io.Copy(tmp, http.MaxBytesReader(w, r.Body, maxBackupSize))
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return wrapAPIError(CodeMalformedBody, err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var manifest api.BackupManifestDto
+	if err := readBackupJSON(files, backupManifestFile, func(d *json.Decoder) error { return d.Decode(&manifest) }); err != nil {
+		return err
+	}
+	if manifest.Format != backupFormat {
+		return apiErrorf(CodeUnprocessable, "archive is not a %s", backupFormat)
+	}
+	if manifest.Version != backupFormatVersion {
+		return apiErrorf(CodeUnprocessable, "backup format version %d is not supported, this server reads version %d", manifest.Version, backupFormatVersion)
+	}
+	people, err := readJSONLines[backupPerson](files, backupPeopleFile)
+	if err != nil {
+		return err
+	}
+	kills, err := readJSONLines[backupKill](files, backupKillsFile)
+	if err != nil {
+		return err
+	}
+	tasks, err := readJSONLines[backupTask](files, backupTasksFile)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if t.Kind != TaskHeartAttack && t.Kind != TaskDeath && t.Kind != TaskKill {
+			return apiErrorf(CodeUnprocessable, "task for person %d has unknown kind %q", t.PersonID, t.Kind)
+		}
+	}
+
+	ctx := r.Context()
+	if err := s.ensureEmptyDatabase(s.PeopleRepository.WithContext(ctx), s.KillRepository.WithContext(ctx)); err != nil {
+		return err
+	}
+
+	// 1) Fotos primero; si la BD falla se borran
+	var restoredPhotos []string
+	removePhotos := func() {
+		for _, name := range restoredPhotos {
+			s.removePhoto(r.Context(), "/static/"+name)
+		}
+	}
+	for _, p := range people {
+		name := photoFileName(p.PhotoURL)
+		f, ok := files[backupPhotosDir+name]
+		if name == "" || !ok {
+			continue
+		}
+		if err := restorePhoto(f, name); err != nil {
+			removePhotos()
+			return err
+		}
+		restoredPhotos = append(restoredPhotos, name)
+	}
+
+	// 2) Personas, kills y tareas, relativas al momento de la restauración,
+	// en una transacción; las tareas se encolan tras el commit
+	restoredAt := time.Now()
+	err = s.UnitOfWork.WithContext(ctx).Do(func(tx *repository.Tx) error {
+		if err := s.ensureEmptyDatabase(tx.People, tx.Kills); err != nil {
+			return err
+		}
+		for _, p := range people {
+			if err := tx.People.Insert(p.toModel()); err != nil {
+				return err
+			}
+		}
+		for _, k := range kills {
+			if err := tx.Kills.Insert(k.toModel()); err != nil {
+				return err
+			}
+		}
+		if err := tx.People.ResetIDSequence(); err != nil {
+			return err
+		}
+		if err := tx.Kills.ResetIDSequence(); err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			remaining := time.Duration(t.RemainingMs) * time.Millisecond
+			if err := s.scheduleTask(ctx, tx, t.Kind, uint(t.PersonID), remaining, t.Description); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		removePhotos()
+		return err
+	}
+
+	report := &api.BackupRestoreReportDto{
+		RestoredAt: restoredAt.UTC().Format(time.RFC3339),
+		Counts: api.BackupCountsDto{
+			People: len(people),
+			Kills:  len(kills),
+			Tasks:  len(tasks),
+			Photos: len(restoredPhotos),
+		},
+	}
+	s.logger.InfoContext(ctx, "backup restored",
+		"backup_created_at", manifest.CreatedAt, "people", report.Counts.People,
+		"kills", report.Counts.Kills, "tasks", report.Counts.Tasks, "photos", report.Counts.Photos)
+	writeJSON(w, http.StatusOK, report)
+	return nil
+}
+
+// ensureEmptyDatabase rechaza restaurar sobre datos existentes, incluidas
+// filas borradas que todavía ocupan IDs
+func (s *Server) ensureEmptyDatabase(people *repository.PeopleRepository, kills *repository.KillRepository) error {
+	peopleCount, err := people.CountAll()
+	if err != nil {
+		return err
+	}
+	killCount, err := kills.CountAll()
+	if err != nil {
+		return err
+	}
+	if peopleCount > 0 || killCount > 0 {
+		return apiErrorf(CodeConflict, "database is not empty (%d people, %d kills), restore needs an empty database", peopleCount, killCount)
+	}
+	return nil
+}
+
+func (p *backupPerson) toModel() *models.Person {
+	person := &models.Person{
+		Name:      p.Name,
+		Age:       p.Age,
+		PhotoPath: p.PhotoURL,
+		Cause:     p.Cause,
+		Details:   p.Details,
+		DeathTime: p.DeathTime,
+		Version:   max(p.Version, 1),
+	}
+	person.ID = p.ID
+	person.CreatedAt = p.CreatedAt
+	person.UpdatedAt = p.UpdatedAt
+	if p.DeletedAt != nil {
+		person.DeletedAt = gorm.DeletedAt{Time: *p.DeletedAt, Valid: true}
+	}
+	return person
+}
+
+func (k *backupKill) toModel() *models.Kill {
+	kill := &models.Kill{
+		Description: k.Description,
+		PersonId:    k.PersonID,
+	}
+	kill.ID = k.ID
+	kill.CreatedAt = k.CreatedAt
+	kill.UpdatedAt = k.UpdatedAt
+	if k.DeletedAt != nil {
+		kill.DeletedAt = gorm.DeletedAt{Time: *k.DeletedAt, Valid: true}
+	}
+	return kill
+}
+
+// readBackupJSON abre un archivo obligatorio del backup y lo pasa a decode
+func readBackupJSON(files map[string]*zip.File, name string, decode func(*json.Decoder) error) error {
+	f, ok := files[name]
+	if !ok {
+		return apiErrorf(CodeBadRequest, "backup is missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return wrapAPIError(CodeMalformedBody, err)
+	}
+	defer rc.Close()
+	decoder := json.NewDecoder(rc)
+	decoder.DisallowUnknownFields()
+	if err := decode(decoder); err != nil {
+		return wrapAPIError(CodeMalformedBody, fmt.Errorf("%s: %w", name, err))
+	}
+	return nil
+}
+
+// readJSONLines lee un archivo JSON Lines del backup, un T por línea
+func readJSONLines[T any](files map[string]*zip.File, name string) ([]T, error) {
+	var items []T
+	err := readBackupJSON(files, name, func(d *json.Decoder) error {
+		for d.More() {
+			var item T
+			if err := d.Decode(&item); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	return items, err
+}
+
+// restorePhoto escribe la foto en uploads/ con su nombre original, vía un
+// temporal como savePhoto. Si ya hay un archivo con ese nombre responde 409
+func restorePhoto(f *zip.File, name string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return wrapAPIError(CodeMalformedBody, err)
+	}
+	defer rc.Close()
+	if err := os.MkdirAll(uploadsDir, os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(uploadsDir, ".restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, newPhotoLimitReader(rc))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	// Link no reemplaza un archivo existente: una foto de uploads/ que no
+	// está en la BD no se pisa con la del backup
+	err = os.Link(tmp.Name(), filepath.Join(uploadsDir, name))
+	if errors.Is(err, fs.ErrExist) {
+		return apiErrorf(CodeConflict, "photo %q already exists in uploads, restore needs those files removed", name)
+	}
+	return err
+}
+
+// photoFileName devuelve el nombre de archivo de una ruta /static/...
+func photoFileName(photoPath string) string {
+	name, ok := strings.CutPrefix(photoPath, "/static/")
+	if !ok || name == "" {
+		return ""
+	}
+	return path.Base(name)
+}
+
+// startOnWrite llama a start antes del primer byte, para poder responder
+// con un error mientras no se haya enviado nada
+type startOnWrite struct {
+	w       io.Writer
+	start   func()
+	started bool
+}
+
+func (s *startOnWrite) Write(p []byte) (int, error) {
+	if !s.started {
+		s.started = true
+		s.start()
+	}
+	return s.w.Write(p)
+}
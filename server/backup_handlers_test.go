@@ -0,0 +1,189 @@
+package server_test
+
+import (
+	"archive/zip"
+	"backend-avanzada/server"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"backend-avanzada/models"
+)
+
+func adminRequest(s *server.Server, method, path string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Authorization", "Bearer "+s.Config.AdminToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/zip")
+	}
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+// removeUpload borra de uploads/ la foto de photoURL, como en un entorno
+// nuevo donde se restaura un backup
+func removeUpload(t *testing.T, photoURL string) {
+	t.Helper()
+	if err := os.Remove(filepath.Join("uploads", path.Base(photoURL))); err != nil {
+		t.Fatalf("borrar la foto: %v", err)
+	}
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusForbidden)
+
+	s.Config.AdminToken = "secreto"
+	req = httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+	req.Header.Set("Authorization", "Bearer otro")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusUnauthorized)
+	if rec.Header().Get("WWW-Authenticate") == "" {
+		t.Error("falta WWW-Authenticate")
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	s := createTestServer(t)
+	s.Config.AdminToken = "secreto"
+	created := createPersonAt(t, s, "/v1/people")
+	id := int(created["person_id"].(float64))
+
+	rec := adminRequest(s, http.MethodGet, "/admin/backup", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("backup: %d %s", rec.Code, rec.Body.String())
+	}
+	backup := rec.Body.Bytes()
+	archive, err := zip.NewReader(bytes.NewReader(backup), int64(len(backup)))
+	if err != nil {
+		t.Fatalf("el backup no es un ZIP: %v", err)
+	}
+	var manifest struct {
+		Version int            `json:"version"`
+		Counts  map[string]int `json:"counts"`
+	}
+	f, _ := archive.Open("manifest.json")
+	json.NewDecoder(f).Decode(&manifest)
+	if manifest.Version != 1 || manifest.Counts["people"] != 1 || manifest.Counts["tasks"] != 1 || manifest.Counts["photos"] != 1 {
+		t.Fatalf("manifiesto inesperado: %+v", manifest)
+	}
+
+	// Restaurar sobre datos existentes no se permite
+	rec = adminRequest(s, http.MethodPost, "/admin/restore", bytes.NewReader(backup))
+	decodeProblem(t, rec, http.StatusConflict)
+
+	// Vaciar la BD y la cola, como en un entorno nuevo
+	s.CancelTaskForTest(id)
+	resetDB(s)
+
+	// La foto sigue en uploads/ y el restore no la pisa
+	rec = adminRequest(s, http.MethodPost, "/admin/restore", bytes.NewReader(backup))
+	decodeProblem(t, rec, http.StatusConflict)
+	if count, _ := s.PeopleRepository.CountAll(); count != 0 {
+		t.Fatalf("un restore fallido dejó %d personas", count)
+	}
+	removeUpload(t, created["photo_url"].(string))
+
+	rec = adminRequest(s, http.MethodPost, "/admin/restore", bytes.NewReader(backup))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore: %d %s", rec.Code, rec.Body.String())
+	}
+	defer s.CancelTaskForTest(id)
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v1/people/%d", id), nil)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("la persona restaurada no existe: %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/status", nil)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	var status struct {
+		PendingTasks int `json:"pending_tasks"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if status.PendingTasks != 1 {
+		t.Errorf("pending_tasks = %d, esperaba la tarea restaurada", status.PendingTasks)
+	}
+}
+
+func TestBackupKeepsDeletedKills(t *testing.T) {
+	s := createTestServer(t)
+	s.Config.AdminToken = "secreto"
+	router := s.GetRouter()
+	created := createPersonAt(t, s, "/v2/people")
+	id := int(created["id"].(float64))
+	defer s.CancelTaskForTest(id)
+	for _, description := range []string{"revocada", "cuaderno"} {
+		kill := &models.Kill{PersonId: uint(id), Description: description}
+		if _, err := s.KillRepository.Save(kill); err != nil {
+			t.Fatalf("kill: %v", err)
+		}
+		if description == "revocada" {
+			if err := s.KillRepository.Delete(kill); err != nil {
+				t.Fatalf("revocar: %v", err)
+			}
+		}
+	}
+	// La persona va a la papelera y arrastra su kill
+	path := fmt.Sprintf("/v2/people/%d", id)
+	if rec := serve(router, http.MethodDelete, path, currentETag(t, router, path)); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec := adminRequest(s, http.MethodGet, "/admin/backup", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("backup: %d %s", rec.Code, rec.Body.String())
+	}
+	backup := rec.Body.Bytes()
+	resetDB(s)
+	removeUpload(t, created["photo_url"].(string))
+	if rec := adminRequest(s, http.MethodPost, "/admin/restore", bytes.NewReader(backup)); rec.Code != http.StatusOK {
+		t.Fatalf("restore: %d %s", rec.Code, rec.Body.String())
+	}
+	if count, err := s.KillRepository.CountAll(); err != nil || count != 2 {
+		t.Fatalf("kills restauradas = %d, %v; esperaba 2", count, err)
+	}
+
+	// Al sacar la persona de la papelera vuelve la kill del borrado, no la revocada
+	if rec := serve(router, http.MethodPost, path+"/restore", ""); rec.Code != http.StatusOK {
+		t.Fatalf("restore de la papelera: %d %s", rec.Code, rec.Body.String())
+	}
+	var kills []struct {
+		Description string `json:"description"`
+	}
+	json.Unmarshal(serve(router, http.MethodGet, "/v2/kills", "").Body.Bytes(), &kills)
+	if len(kills) != 1 || kills[0].Description != "cuaderno" {
+		t.Errorf("kills tras restaurar la persona: %+v", kills)
+	}
+}
+
+func TestRestoreRejectsUnknownVersion(t *testing.T) {
+	s := createTestServer(t)
+	s.Config.AdminToken = "secreto"
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("manifest.json")
+	w.Write([]byte(`{"format":"deathnote-backup","version":99,"created_at":"2026-10-19T00:00:00Z","app_version":"dev","counts":{"people":0,"kills":0,"tasks":0,"photos":0}}`))
+	zw.Close()
+
+	rec := adminRequest(s, http.MethodPost, "/admin/restore", &buf)
+	decodeProblem(t, rec, http.StatusUnprocessableEntity)
+}
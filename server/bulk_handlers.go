@@ -0,0 +1,377 @@
+package server
+
+import (
+	"archive/zip"
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxBulkSize limita el ZIP completo (100 MB)
+	maxBulkSize     = 100 << 20
+	maxManifestSize = 1 << 20
+	bulkModeAtomic  = "atomic"
+	bulkModePartial = "partial"
+)
+
+// bulkRow es una fila del manifiesto (manifest.csv o manifest.json)
+type bulkRow struct {
+	Name    string  `json:"name"`
+	Age     int     `json:"age"`
+	Photo   string  `json:"photo"`
+	Cause   *string `json:"cause"`
+	Details *string `json:"details"`
+}
+
+// bulkItem es el estado de una fila durante la importación
+type bulkItem struct {
+	row       bulkRow
+	result    *api.BulkRowResultDto
+	photoPath string
+	person    *models.Person
+}
+
+// HandleBulkCreatePeople crea personas a partir de un ZIP con un manifiesto
+// y sus fotos. En modo atomic (por defecto) cualquier fila inválida
+// cancela la importación entera; en modo partial se crean las válidas y el
+// informe indica qué filas fallaron y por qué
+func (s *Server) HandleBulkCreatePeople(w http.ResponseWriter, r *http.Request) error {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = bulkModeAtomic
+	}
+	if mode != bulkModeAtomic && mode != bulkModePartial {
+		return apiErrorf(CodeBadRequest, "mode must be %q or %q", bulkModeAtomic, bulkModePartial)
+	}
+
+	// El ZIP se guarda en disco para no tenerlo entero en memoria, como en
+	// /admin/restore
+	tmp, err := os.CreateTemp("", "deathnote-bulk-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	size, err := io.Copy(tmp, http.MaxBytesReader(w, r.Body, maxBulkSize))
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return wrapAPIError(CodeMalformedBody, err)
+	}
+	rows, err := readManifest(archive)
+	if err != nil {
+		return err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[path.Clean(f.Name)] = f
+	}
+
+	report := &api.BulkImportReportDto{Mode: mode, Total: len(rows), Rows: make([]api.BulkRowResultDto, len(rows))}
+	items := make([]*bulkItem, len(rows))
+	for i, row := range rows {
+		report.Rows[i] = api.BulkRowResultDto{Row: i + 1, Name: row.Name, Status: "failed"}
+		items[i] = &bulkItem{row: row, result: &report.Rows[i]}
+	}
+
+	// 1) Validar cada fila y guardar su foto
+	for _, item := range items {
+		item.result.Errors = validateBulkRow(item.row, files)
+		if len(item.result.Errors) > 0 {
+			continue
+		}
+		photoPath, err := s.saveBulkPhoto(files[path.Clean(item.row.Photo)])
+		if err != nil {
+			item.result.Errors = []string{"photo: " + apiErrorDetail(err)}
+			continue
+		}
+		item.photoPath = photoPath
+	}
+	if mode == bulkModeAtomic {
+		if err := atomicBulkErrors(items); err != nil {
+			s.removeBulkPhotos(r, items)
+			return err
+		}
+	}
+
+	// 2) Guardar en BD con su línea de tiempo: todo en una unidad de trabajo
+	// o una por fila. Las muertes solo se programan tras el commit
+	uow := s.UnitOfWork.WithContext(r.Context())
+	if mode == bulkModeAtomic {
+		err := uow.Do(func(tx *repository.Tx) error {
+			for _, item := range items {
+				if err := s.saveBulkPerson(r, tx, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			s.removeBulkPhotos(r, items)
+			return err
+		}
+	} else {
+		for _, item := range items {
+			if item.photoPath == "" {
+				continue
+			}
+			err := uow.Do(func(tx *repository.Tx) error {
+				return s.saveBulkPerson(r, tx, item)
+			})
+			if err != nil {
+				s.logger.ErrorContext(r.Context(), "bulk row failed", "row", item.result.Row, "error", err)
+				item.result.Errors = []string{"could not be saved"}
+				s.removePhoto(r.Context(), item.photoPath)
+			}
+		}
+	}
+
+	// 3) Informe: solo cuenta lo que quedó guardado
+	for _, item := range items {
+		if item.person == nil {
+			report.Failed++
+			continue
+		}
+		report.Created++
+		item.result.Status = "created"
+		item.result.PersonID = &item.person.ID
+	}
+
+	status := http.StatusOK
+	if mode == bulkModeAtomic {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, report)
+	return nil
+}
+
+// readManifest lee manifest.json o manifest.csv de la raíz del ZIP
+func readManifest(archive *zip.Reader) ([]bulkRow, error) {
+	var manifest *zip.File
+	for _, f := range archive.File {
+		switch strings.ToLower(f.Name) {
+		case "manifest.json", "manifest.csv":
+			if manifest != nil {
+				return nil, apiErrorf(CodeBadRequest, "ZIP must contain only one manifest")
+			}
+			manifest = f
+		}
+	}
+	if manifest == nil {
+		return nil, apiErrorf(CodeBadRequest, "ZIP must contain manifest.csv or manifest.json")
+	}
+	rc, err := manifest.Open()
+	if err != nil {
+		return nil, wrapAPIError(CodeMalformedBody, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxManifestSize+1))
+	if err != nil {
+		return nil, wrapAPIError(CodeMalformedBody, err)
+	}
+	if len(data) > maxManifestSize {
+		return nil, apiErrorf(CodePayloadTooLarge, "manifest exceeds %d bytes", maxManifestSize)
+	}
+
+	var rows []bulkRow
+	if strings.HasSuffix(strings.ToLower(manifest.Name), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rows); err != nil {
+			return nil, wrapAPIError(CodeMalformedBody, fmt.Errorf("manifest.json: %w", err))
+		}
+	} else if rows, err = readCSVManifest(data); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, apiErrorf(CodeBadRequest, "manifest has no rows")
+	}
+	return rows, nil
+}
+
+// readCSVManifest lee un CSV con cabecera name,age,photo[,cause][,details]
+// en cualquier orden. Las celdas vacías de cause y details se ignoran
+func readCSVManifest(data []byte) ([]bulkRow, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, wrapAPIError(CodeMalformedBody, fmt.Errorf("manifest.csv: %w", err))
+	}
+	if len(records) == 0 {
+		return nil, apiErrorf(CodeBadRequest, "manifest.csv has no header")
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "age", "photo"} {
+		if _, ok := columns[required]; !ok {
+			return nil, apiErrorf(CodeBadRequest, "manifest.csv is missing the %q column", required)
+		}
+	}
+	cell := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	optional := func(record []string, column string) *string {
+		if value := cell(record, column); value != "" {
+			return &value
+		}
+		return nil
+	}
+
+	rows := make([]bulkRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		// Una edad que no es un número se queda en 0 y la validación la rechaza
+		age, _ := strconv.Atoi(cell(record, "age"))
+		rows = append(rows, bulkRow{
+			Name:    cell(record, "name"),
+			Age:     age,
+			Photo:   cell(record, "photo"),
+			Cause:   optional(record, "cause"),
+			Details: optional(record, "details"),
+		})
+	}
+	return rows, nil
+}
+
+// validateBulkRow aplica las mismas reglas que POST /people
+func validateBulkRow(row bulkRow, files map[string]*zip.File) []string {
+	var errs []string
+	if strings.TrimSpace(row.Name) == "" {
+		errs = append(errs, "name: is required")
+	}
+	if row.Age < 1 {
+		errs = append(errs, "age: must be a positive integer")
+	}
+	switch f, ok := files[path.Clean(row.Photo)]; {
+	case row.Photo == "":
+		errs = append(errs, "photo: is required")
+	case !ok:
+		errs = append(errs, fmt.Sprintf("photo: %q is not in the ZIP", row.Photo))
+	case f.UncompressedSize64 > maxUploadSize:
+		errs = append(errs, fmt.Sprintf("photo: exceeds %d bytes", maxUploadSize))
+	}
+	return errs
+}
+
+// atomicBulkErrors junta los errores de todas las filas en un único 422
+func atomicBulkErrors(items []*bulkItem) error {
+	var fieldErrors []api.FieldError
+	for _, item := range items {
+		for _, msg := range item.result.Errors {
+			field, message, _ := strings.Cut(msg, ": ")
+			fieldErrors = append(fieldErrors, api.FieldError{
+				In:      "manifest",
+				Field:   fmt.Sprintf("rows[%d].%s", item.result.Row, field),
+				Message: message,
+			})
+		}
+	}
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+	return &APIError{
+		Code:   CodeUnprocessable,
+		Detail: fmt.Sprintf("%d problem(s) in the manifest, nothing was imported", len(fieldErrors)),
+		Errors: fieldErrors,
+	}
+}
+
+func (s *Server) saveBulkPhoto(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", wrapAPIError(CodeMalformedBody, err)
+	}
+	defer rc.Close()
+	photoPath, err := s.savePhoto(newPhotoLimitReader(rc), f.Name)
+	if errors.Is(err, zip.ErrFormat) || errors.Is(err, zip.ErrChecksum) {
+		return "", apiErrorf(CodeMalformedBody, "does not match its ZIP header")
+	}
+	return photoPath, err
+}
+
+func (s *Server) removeBulkPhotos(r *http.Request, items []*bulkItem) {
+	for _, item := range items {
+		if item.photoPath != "" {
+			s.removePhoto(r.Context(), item.photoPath)
+		}
+	}
+}
+
+// saveBulkPerson guarda la fila, su línea de tiempo y su muerte en tx. La
+// persona solo pasa a item, y su muerte a la cola, cuando tx se confirma
+func (s *Server) saveBulkPerson(r *http.Request, tx *repository.Tx, item *bulkItem) error {
+	person, err := tx.People.Save(&models.Person{
+		Name:      item.row.Name,
+		Age:       item.row.Age,
+		PhotoPath: item.photoPath,
+		Cause:     item.row.Cause,
+		Details:   item.row.Details,
+		Version:   1,
+	})
+	if err != nil {
+		return err
+	}
+	if err := recordBulkEvents(tx.Events, item.row, person); err != nil {
+		return err
+	}
+	tx.AfterCommit(func() { item.person = person })
+	return s.scheduleBulkDeath(r.Context(), tx, item.row, person)
+}
+
+// recordBulkEvents escribe la línea de tiempo de una fila creada
+func recordBulkEvents(events *repository.EventRepository, row bulkRow, person *models.Person) error {
+	if err := recordCreated(events, person); err != nil {
+		return err
+	}
+	if row.Cause != nil {
+		if err := events.Record(person.ID, models.EventCauseAdded, map[string]any{"cause": *row.Cause}); err != nil {
+			return err
+		}
+	}
+	if row.Details != nil {
+		return events.Record(person.ID, models.EventDetailsAdded, map[string]any{"details": *row.Details})
+	}
+	return nil
+}
+
+// scheduleBulkDeath programa en tx la muerte como si la fila se hubiera
+// creado con POST /people seguido de /cause y /details
+func (s *Server) scheduleBulkDeath(ctx context.Context, tx *repository.Tx, row bulkRow, person *models.Person) error {
+	id := int(person.ID)
+	switch {
+	case row.Details != nil:
+		return s.scheduleDeath(ctx, tx, id, time.Duration(s.Config.KillDuration)*time.Second)
+	case row.Cause != nil:
+		return s.scheduleDeath(ctx, tx, id, time.Duration(s.Config.KillDurationWithDescription)*time.Second)
+	default:
+		return s.scheduleHeartAttack(ctx, tx, person)
+	}
+}
+
+// apiErrorDetail devuelve el detalle visible de un APIError o un mensaje
+// genérico para el resto
+func apiErrorDetail(err error) string {
+	if apiErr, ok := err.(*APIError); ok {
+		return apiErr.Detail
+	}
+	return "could not be stored"
+}
@@ -0,0 +1,153 @@
+package server_test
+
+import (
+	"archive/zip"
+	"backend-avanzada/server"
+	"bytes"
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// bulkZip arma un ZIP con el manifiesto y light.jpg
+func bulkZip(t *testing.T, manifestName, manifest string) *bytes.Buffer {
+	photo, err := os.ReadFile("./testdata/light.jpg")
+	if err != nil {
+		t.Fatalf("falta ./testdata/light.jpg: %v", err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create(manifestName)
+	f.Write([]byte(manifest))
+	f, _ = zw.Create("fotos/light.jpg")
+	f.Write(photo)
+	zw.Close()
+	return &buf
+}
+
+func postBulk(s *server.Server, query string, body *bytes.Buffer) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/people/bulk"+query, body)
+	req.Header.Set("Content-Type", "application/zip")
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+type bulkReport struct {
+	Created int `json:"created"`
+	Failed  int `json:"failed"`
+	Rows    []struct {
+		Row      int      `json:"row"`
+		Status   string   `json:"status"`
+		PersonID *int     `json:"person_id"`
+		Errors   []string `json:"errors"`
+	} `json:"rows"`
+}
+
+func cancelBulkTasks(s *server.Server, report *bulkReport) {
+	for _, row := range report.Rows {
+		if row.PersonID != nil {
+			s.CancelTaskForTest(*row.PersonID)
+		}
+	}
+}
+
+func countPeople(t *testing.T, s *server.Server) int {
+	req := httptest.NewRequest(http.MethodGet, "/v1/people", nil)
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	var people []map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &people)
+	return len(people)
+}
+
+func TestBulkCreateAtomic(t *testing.T) {
+	s := createTestServer(t)
+
+	manifest := "name,age,photo,cause\nRem,200,fotos/light.jpg,\nGelus,300,fotos/light.jpg,accidente\n"
+	rec := postBulk(s, "", bulkZip(t, "manifest.csv", manifest))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("esperado 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var report bulkReport
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	defer cancelBulkTasks(s, &report)
+	if report.Created != 2 || report.Failed != 0 || countPeople(t, s) != 2 {
+		t.Fatalf("informe inesperado: %s", rec.Body.String())
+	}
+
+	// Una fila inválida cancela todo
+	manifest = "name,age,photo\nSidoh,100,fotos/light.jpg\nArmonia,0,fotos/falta.jpg\n"
+	rec = postBulk(s, "?mode=atomic", bulkZip(t, "manifest.csv", manifest))
+	problem := decodeProblem(t, rec, http.StatusUnprocessableEntity)
+	if len(problem.Errors) != 2 || problem.Errors[0].Field != "rows[2].age" || problem.Errors[1].Field != "rows[2].photo" {
+		t.Errorf("errores inesperados: %+v", problem.Errors)
+	}
+	if n := countPeople(t, s); n != 2 {
+		t.Errorf("no debería haberse creado nadie más, hay %d personas", n)
+	}
+}
+
+func TestBulkCreatePartial(t *testing.T) {
+	s := createTestServer(t)
+
+	manifest := `[
+		{"name": "Ryuk", "age": 1000, "photo": "fotos/light.jpg", "details": "en el tren"},
+		{"name": "", "age": 20, "photo": "fotos/light.jpg"}
+	]`
+	rec := postBulk(s, "?mode=partial", bulkZip(t, "manifest.json", manifest))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var report bulkReport
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	defer cancelBulkTasks(s, &report)
+	if report.Created != 1 || report.Failed != 1 {
+		t.Fatalf("informe inesperado: %s", rec.Body.String())
+	}
+	if report.Rows[0].Status != "created" || report.Rows[1].Status != "failed" || len(report.Rows[1].Errors) != 1 {
+		t.Errorf("filas inesperadas: %+v", report.Rows)
+	}
+}
+
+func TestBulkCreateRejectsUnderstatedPhotoSize(t *testing.T) {
+	s := createTestServer(t)
+	photo, err := os.ReadFile("./testdata/light.jpg")
+	if err != nil {
+		t.Fatalf("falta ./testdata/light.jpg: %v", err)
+	}
+	// La cabecera declara justo el límite, pero la foto lo pasa en un byte
+	const limit = 10 << 20
+	photo = append(photo, make([]byte, limit+1-len(photo))...)
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("manifest.csv")
+	f.Write([]byte("name,age,photo\nMisa,19,fotos/light.jpg\n"))
+	f, err = zw.CreateRaw(&zip.FileHeader{
+		Name:               "fotos/light.jpg",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(photo),
+		CompressedSize64:   uint64(len(photo)),
+		UncompressedSize64: limit,
+	})
+	if err != nil {
+		t.Fatalf("CreateRaw() error: %v", err)
+	}
+	f.Write(photo)
+	zw.Close()
+
+	rec := postBulk(s, "?mode=partial", &buf)
+	var report bulkReport
+	json.Unmarshal(rec.Body.Bytes(), &report)
+	defer cancelBulkTasks(s, &report)
+	if report.Created != 0 || report.Failed != 1 || !strings.HasPrefix(report.Rows[0].Errors[0], "photo: ") {
+		t.Fatalf("la foto truncada no debería aceptarse: %d %s", rec.Code, rec.Body.String())
+	}
+	if n := countPeople(t, s); n != 0 {
+		t.Errorf("no debería haberse creado nadie, hay %d personas", n)
+	}
+}
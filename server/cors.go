@@ -6,8 +6,9 @@ import "net/http"
 func MiddlewareCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, If-Match, If-None-Match, Idempotency-Key, X-Actor")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag, Idempotent-Replayed")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
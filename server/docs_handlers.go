@@ -0,0 +1,61 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"net/http"
+)
+
+// HandleOpenAPI sirve la especificación OpenAPI embebida
+func (s *Server) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(api.OpenAPISpec)
+}
+
+// docsCSP solo deja cargar recursos del propio servidor: la página no
+// depende de ningún CDN
+const docsCSP = "default-src 'self'; script-src 'self'; style-src 'self'; img-src 'self' data:"
+
+// redocCSP es docsCSP con lo que Redoc necesita: estilos en línea y el
+// worker de búsqueda, que crea desde un blob
+const redocCSP = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; worker-src 'self' blob:"
+
+// redocBundle es el Redoc vendorizado en api/redoc; sin él /docs usa el
+// renderer de api/docs.js
+var redocBundle = api.RedocBundle()
+
+// HandleDocs sirve la documentación interactiva que consume /openapi.json:
+// Redoc si está vendorizado y si no el renderer propio
+func (s *Server) HandleDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if redocBundle != nil {
+		w.Header().Set("Content-Security-Policy", redocCSP)
+		w.Write(api.RedocPage)
+		return
+	}
+	w.Header().Set("Content-Security-Policy", docsCSP)
+	w.Write(api.DocsPage)
+}
+
+// HandleRedoc sirve el bundle de Redoc embebido, o 404 si no está
+// vendorizado
+func (s *Server) HandleRedoc(w http.ResponseWriter, r *http.Request) {
+	if redocBundle == nil {
+		s.notFoundHandler().ServeHTTP(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(redocBundle)
+}
+
+// HandleDocsScript sirve el script embebido que renderiza /docs
+func (s *Server) HandleDocsScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Write(api.DocsScript)
+}
+
+// HandleDocsStyle sirve la hoja de estilos de /docs
+func (s *Server) HandleDocsStyle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Write(api.DocsStyle)
+}
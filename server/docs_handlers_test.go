@@ -0,0 +1,104 @@
+package server_test
+
+import (
+	"backend-avanzada/api"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+type openAPIDoc struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadSpec(t *testing.T) *openAPIDoc {
+	var doc openAPIDoc
+	if err := json.Unmarshal(api.OpenAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json no es JSON válido: %v", err)
+	}
+	return &doc
+}
+
+// TestOpenAPICoversRouter falla si una ruta registrada en Routes no está
+// documentada en openapi.json o si el documento describe rutas que no existen
+func TestOpenAPICoversRouter(t *testing.T) {
+	s := createTestServer(t)
+	router := s.Routes()
+	doc := loadSpec(t)
+
+	registered := map[string]bool{}
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // PathPrefix sin métodos, p. ej. /static/
+		}
+		for _, m := range methods {
+			if m == http.MethodOptions {
+				continue
+			}
+			key := strings.ToLower(m) + " " + tpl
+			registered[key] = true
+			if _, ok := doc.Paths[tpl][strings.ToLower(m)]; !ok {
+				t.Errorf("ruta %s %s no está en openapi.json", m, tpl)
+			}
+		}
+		return nil
+	})
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			if !registered[method+" "+path] {
+				t.Errorf("openapi.json describe %s %s pero no está en GetRouter", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestServeOpenAPIAndDocs(t *testing.T) {
+	s := createTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("esperado 200 JSON, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/docs", nil)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/openapi.json") {
+		t.Fatalf("esperado 200 con la página de documentación, got %d", rec.Code)
+	}
+	// Todo sale del binario: nada de CDN
+	if strings.Contains(rec.Body.String(), "https://") || !strings.Contains(rec.Header().Get("Content-Security-Policy"), "script-src 'self'") {
+		t.Errorf("la página carga recursos externos: %s", rec.Body.String())
+	}
+	// Redoc solo se sirve si está vendorizado en api/redoc
+	want := http.StatusNotFound
+	if api.RedocBundle() != nil {
+		want = http.StatusOK
+	}
+	if rec := serve(s.GetRouter(), http.MethodGet, "/docs/redoc.standalone.js", ""); rec.Code != want {
+		t.Errorf("/docs/redoc.standalone.js: %d, esperaba %d", rec.Code, want)
+	}
+	for path, contentType := range map[string]string{"/docs/docs.js": "text/javascript", "/docs/docs.css": "text/css"} {
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		rec = httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), contentType) || rec.Body.Len() == 0 {
+			t.Errorf("%s: %d %q", path, rec.Code, rec.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"backend-avanzada/api"
+)
+
+func TestDocsServeVendoredRedoc(t *testing.T) {
+	defer func(bundle []byte) { redocBundle = bundle }(redocBundle)
+	redocBundle = []byte("/* redoc */")
+	s := &Server{}
+
+	rec := httptest.NewRecorder()
+	s.HandleDocs(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if rec.Body.String() != string(api.RedocPage) || !strings.Contains(rec.Body.String(), "/docs/redoc.standalone.js") {
+		t.Fatalf("/docs no carga Redoc: %s", rec.Body.String())
+	}
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'self';") || strings.Contains(csp, "https:") {
+		t.Errorf("CSP de Redoc: %q", csp)
+	}
+
+	rec = httptest.NewRecorder()
+	s.HandleRedoc(rec, httptest.NewRequest(http.MethodGet, "/docs/redoc.standalone.js", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "/* redoc */" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/javascript") {
+		t.Errorf("bundle: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
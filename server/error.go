@@ -2,32 +2,200 @@ package server
 
 import (
 	"backend-avanzada/api"
+	"backend-avanzada/logger"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// ErrorCode identifica cada tipo de problema; el cliente puede depender de él
+type ErrorCode string
+
+const (
+	CodeBadRequest            ErrorCode = "bad_request"
+	CodeValidationFailed      ErrorCode = "validation_failed"
+	CodeInvalidID             ErrorCode = "invalid_id"
+	CodeMalformedBody         ErrorCode = "malformed_body"
+	CodeUnauthorized          ErrorCode = "unauthorized"
+	CodeForbidden             ErrorCode = "forbidden"
+	CodeRouteNotFound         ErrorCode = "route_not_found"
+	CodePersonNotFound        ErrorCode = "person_not_found"
+	CodeMethodNotAllowed      ErrorCode = "method_not_allowed"
+	CodeConflict              ErrorCode = "conflict"
+	CodeTaskInProgress        ErrorCode = "task_in_progress"
+	CodeIdempotencyInProgress ErrorCode = "idempotency_key_in_progress"
+	CodeIdempotencyKeyReused  ErrorCode = "idempotency_key_reused"
+	CodeKillExists            ErrorCode = "kill_already_exists"
+	CodeKillNotFound          ErrorCode = "kill_not_found"
+	CodeWebhookNotFound       ErrorCode = "webhook_not_found"
+	CodePreconditionFailed    ErrorCode = "precondition_failed"
+	CodePreconditionRequired  ErrorCode = "precondition_required"
+	CodePayloadTooLarge       ErrorCode = "payload_too_large"
+	CodeUnprocessable         ErrorCode = "unprocessable_entity"
+	CodeTooManyRequests       ErrorCode = "too_many_requests"
+	CodeUnsupportedMedia      ErrorCode = "unsupported_media_type"
+	CodeInternal              ErrorCode = "internal_error"
+	CodeUnavailable           ErrorCode = "service_unavailable"
 )
 
-var statusMap = map[int]string{
-	400: "Bad Request",
-	404: "Not Found",
-	405: "Method Not Allowed",
-	500: "Internal Server Error",
-	200: "OK",
-	201: "Created",
-	202: "Accepted",
-	204: "No Content",
-}
-
-func (s *Server) HandleError(w http.ResponseWriter, statusCode int, path string, cause error) {
-	var errorResponse api.ErrorResponse
-	errorResponse.Status = statusCode
-	errorResponse.Message = cause.Error()
-	errorResponse.Description = statusMap[statusCode]
-	response, err := json.Marshal(errorResponse)
-	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, path, err)
-	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	w.Write(response)
-	s.logger.Error(statusCode, path, cause)
+type problemType struct {
+	status int
+	title  string
+}
+
+// problemCatalog es el catálogo de errores que se publica en /problems
+var problemCatalog = map[ErrorCode]problemType{
+	CodeBadRequest:            {http.StatusBadRequest, "Bad request"},
+	CodeValidationFailed:      {http.StatusBadRequest, "Request validation failed"},
+	CodeInvalidID:             {http.StatusBadRequest, "Invalid id"},
+	CodeMalformedBody:         {http.StatusBadRequest, "Malformed request body"},
+	CodeUnauthorized:          {http.StatusUnauthorized, "Authentication required"},
+	CodeForbidden:             {http.StatusForbidden, "Operation not allowed"},
+	CodeRouteNotFound:         {http.StatusNotFound, "Route not found"},
+	CodePersonNotFound:        {http.StatusNotFound, "Person not found"},
+	CodeMethodNotAllowed:      {http.StatusMethodNotAllowed, "Method not allowed"},
+	CodeConflict:              {http.StatusConflict, "Conflict with the current state"},
+	CodeTaskInProgress:        {http.StatusConflict, "Task already in progress"},
+	CodeIdempotencyInProgress: {http.StatusConflict, "Request with this idempotency key in progress"},
+	CodeIdempotencyKeyReused:  {http.StatusUnprocessableEntity, "Idempotency key reused with a different request"},
+	CodeKillExists:            {http.StatusConflict, "Kill already exists"},
+	CodeKillNotFound:          {http.StatusNotFound, "Kill not found"},
+	CodeWebhookNotFound:       {http.StatusNotFound, "Webhook not found"},
+	CodePreconditionFailed:    {http.StatusPreconditionFailed, "Precondition failed"},
+	CodePreconditionRequired:  {http.StatusPreconditionRequired, "Precondition required"},
+	CodePayloadTooLarge:       {http.StatusRequestEntityTooLarge, "Payload too large"},
+	CodeUnprocessable:         {http.StatusUnprocessableEntity, "Unprocessable entity"},
+	CodeTooManyRequests:       {http.StatusTooManyRequests, "Too many requests"},
+	CodeUnsupportedMedia:      {http.StatusUnsupportedMediaType, "Unsupported media type"},
+	CodeInternal:              {http.StatusInternalServerError, "Internal server error"},
+	CodeUnavailable:           {http.StatusServiceUnavailable, "Service unavailable"},
+}
+
+func problemTypeURI(code ErrorCode) string {
+	return "/problems/" + string(code)
+}
+
+// APIError es el error tipado que devuelven los handlers; HandleError lo
+// convierte en application/problem+json
+type APIError struct {
+	Code   ErrorCode
+	Detail string
+	Errors []api.FieldError
+	Err    error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// apiErrorf crea un APIError con un detalle visible para el cliente
+func apiErrorf(code ErrorCode, format string, args ...any) *APIError {
+	return &APIError{Code: code, Detail: fmt.Sprintf(format, args...)}
+}
+
+// wrapAPIError conserva la causa original para el log y usa su mensaje
+// como detalle
+func wrapAPIError(code ErrorCode, err error) *APIError {
+	return &APIError{Code: code, Detail: err.Error(), Err: err}
+}
+
+// handlerFunc es un handler que devuelve un error en lugar de escribirlo
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// handle adapta un handlerFunc a http.HandlerFunc pasando los errores por
+// HandleError
+func (s *Server) handle(h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h(w, r); err != nil {
+			s.HandleError(w, r, err)
+		}
+	}
+}
+
+// HandleError es el único punto que convierte errores en respuestas. Los
+// errores que no son APIError se tratan como 500 y su mensaje solo va al log
+func (s *Server) HandleError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *APIError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.As(err, &maxBytesErr):
+		apiErr = &APIError{Code: CodePayloadTooLarge, Detail: fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit), Err: err}
+	default:
+		apiErr = &APIError{Code: CodeInternal, Detail: "an unexpected error occurred", Err: err}
+	}
+
+	pt, ok := problemCatalog[apiErr.Code]
+	if !ok {
+		pt = problemCatalog[CodeInternal]
+	}
+	problem := &api.Problem{
+		Type:      problemTypeURI(apiErr.Code),
+		Title:     pt.title,
+		Status:    pt.status,
+		Detail:    apiErr.Detail,
+		Instance:  r.URL.Path,
+		Code:      string(apiErr.Code),
+		RequestID: logger.RequestIDFromContext(r.Context()),
+		Errors:    apiErr.Errors,
+	}
+	w.Header().Set("Content-Type", api.ProblemContentType)
+	w.WriteHeader(pt.status)
+	json.NewEncoder(w).Encode(problem)
+	s.logger.RequestError(r.Context(), pt.status, r.URL.Path, err)
+}
+
+// HandleProblemTypes publica el catálogo de códigos de error
+func (s *Server) HandleProblemTypes(w http.ResponseWriter, r *http.Request) {
+	result := make([]*api.ProblemTypeDto, 0, len(problemCatalog))
+	for code, pt := range problemCatalog {
+		result = append(result, problemTypeDto(code, pt))
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Code < result[j].Code })
+	writeJSON(w, http.StatusOK, result)
+}
+
+// HandleProblemType describe un código de error concreto
+func (s *Server) HandleProblemType(w http.ResponseWriter, r *http.Request) error {
+	code := ErrorCode(mux.Vars(r)["code"])
+	pt, ok := problemCatalog[code]
+	if !ok {
+		return apiErrorf(CodeRouteNotFound, "unknown problem type %q", code)
+	}
+	writeJSON(w, http.StatusOK, problemTypeDto(code, pt))
+	return nil
+}
+
+func problemTypeDto(code ErrorCode, pt problemType) *api.ProblemTypeDto {
+	return &api.ProblemTypeDto{
+		Code:   string(code),
+		Type:   problemTypeURI(code),
+		Title:  pt.title,
+		Status: pt.status,
+	}
+}
+
+// notFoundHandler y methodNotAllowedHandler responden con problem+json a
+// las peticiones que mux no llega a enrutar
+func (s *Server) notFoundHandler() http.Handler {
+	return s.handle(func(w http.ResponseWriter, r *http.Request) error {
+		return apiErrorf(CodeRouteNotFound, "no route for %s", r.URL.Path)
+	})
+}
+
+func (s *Server) methodNotAllowedHandler() http.Handler {
+	return s.handle(func(w http.ResponseWriter, r *http.Request) error {
+		return apiErrorf(CodeMethodNotAllowed, "method %s not allowed on %s", r.Method, r.URL.Path)
+	})
 }
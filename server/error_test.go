@@ -0,0 +1,111 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type problemBody struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id"`
+	Errors    []struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder, status int) *problemBody {
+	if rec.Code != status {
+		t.Fatalf("esperado %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, esperaba application/problem+json", ct)
+	}
+	var p problemBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("respuesta no es JSON: %s", rec.Body.String())
+	}
+	if p.Status != status || p.Title == "" || p.Type != "/problems/"+p.Code {
+		t.Errorf("problem incompleto: %+v", p)
+	}
+	return &p
+}
+
+func TestProblemPersonNotFound(t *testing.T) {
+	s := createTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/people/999999/status", nil)
+	req.Header.Set("X-Request-ID", "req-404")
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+
+	p := decodeProblem(t, rec, http.StatusNotFound)
+	if p.Code != "person_not_found" || p.Instance != "/people/999999/status" || p.RequestID != "req-404" {
+		t.Errorf("problem inesperado: %+v", p)
+	}
+}
+
+func TestProblemMalformedBodyAndRouting(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+
+	req := httptest.NewRequest(http.MethodPut, "/people/1", strings.NewReader(`{`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusBadRequest)
+
+	req = httptest.NewRequest(http.MethodGet, "/nada", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if p := decodeProblem(t, rec, http.StatusNotFound); p.Code != "route_not_found" {
+		t.Errorf("code = %q, esperaba route_not_found", p.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/kills", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if p := decodeProblem(t, rec, http.StatusMethodNotAllowed); p.Code != "method_not_allowed" {
+		t.Errorf("code = %q, esperaba method_not_allowed", p.Code)
+	}
+}
+
+func TestProblemCatalog(t *testing.T) {
+	s := createTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/problems", nil)
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d", rec.Code)
+	}
+	var catalog []struct {
+		Code   string `json:"code"`
+		Status int    `json:"status"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &catalog)
+	statuses := map[int]bool{}
+	for _, c := range catalog {
+		statuses[c.Status] = true
+	}
+	for _, st := range []int{400, 401, 403, 404, 405, 409, 413, 422, 429, 500} {
+		if !statuses[st] {
+			t.Errorf("el catálogo no cubre el status %d", st)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/problems/task_in_progress", nil)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"status":409`) {
+		t.Errorf("esperaba la descripción de task_in_progress: %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// personETag es un ETag fuerte derivado de la versión de la persona, que
+// cambia con cada escritura (edición, causa, muerte programada...)
+func personETag(p *models.Person) string {
+	return fmt.Sprintf(`"%d.%d"`, p.ID, p.Version)
+}
+
+// contentETag es un ETag fuerte derivado del cuerpo, para colecciones que
+// no tienen versión propia como /kills
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// notModified fija el ETag y, si coincide con If-None-Match, responde 304
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if !etagListMatches(r.Header.Get("If-None-Match"), etag, true) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// checkIfMatch exige If-Match en las escrituras: sin cabecera es un 428 y si
+// no coincide con el ETag actual un 412
+func checkIfMatch(r *http.Request, etag string) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return apiErrorf(CodePreconditionRequired, "If-Match header is required to modify this resource")
+	}
+	if !etagListMatches(header, etag, false) {
+		return apiErrorf(CodePreconditionFailed, "resource has changed, current ETag is %s", etag)
+	}
+	return nil
+}
+
+// staleVersion es el 412 de una escritura condicional que perdió la carrera
+// después de pasar checkIfMatch
+func staleVersion(id int) error {
+	return apiErrorf(CodePreconditionFailed, "person %d was modified concurrently", id)
+}
+
+// etagListMatches compara un If-Match/If-None-Match ("*" o lista separada
+// por comas). If-None-Match usa comparación débil e If-Match fuerte (RFC 9110)
+func etagListMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writePersonJSON responde con la persona y su ETag, o 304 si el cliente ya
+// tiene esa versión
+func writePersonJSON(w http.ResponseWriter, r *http.Request, status int, person *models.Person, v any) {
+	if r.Method == http.MethodGet {
+		if notModified(w, r, personETag(person)) {
+			return
+		}
+	} else {
+		w.Header().Set("ETag", personETag(person))
+	}
+	writeJSON(w, status, v)
+}
+
+// writeCollectionJSON responde con un ETag calculado sobre el cuerpo
+func writeCollectionJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if notModified(w, r, contentETag(body)) {
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+	return nil
+}
@@ -0,0 +1,88 @@
+package server_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// currentETag hace GET sobre path y devuelve su ETag
+func currentETag(t *testing.T, h http.Handler, path string) string {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET %s: esperado 200 con ETag, got %d %q", path, rec.Code, etag)
+	}
+	return etag
+}
+
+func TestIfNoneMatchReturnsNotModified(t *testing.T) {
+	s := createTestServer(t)
+	created := createPersonAt(t, s, "/v1/people")
+	id := int(created["person_id"].(float64))
+	s.CancelTaskForTest(id)
+
+	for _, path := range []string{"/v1/people/" + strconv.Itoa(id), "/v2/people/" + strconv.Itoa(id), "/v1/kills"} {
+		etag := currentETag(t, s.GetRouter(), path)
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("If-None-Match", "W/"+etag)
+		rec := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("%s: esperado 304 sin cuerpo, got %d %q", path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestWritesRequireIfMatch(t *testing.T) {
+	s := createTestServer(t)
+	created := createPersonAt(t, s, "/v1/people")
+	id := int(created["person_id"].(float64))
+	s.CancelTaskForTest(id)
+	path := "/v1/people/" + strconv.Itoa(id)
+	etag := currentETag(t, s.GetRouter(), path)
+
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(`{"name":"Mello","age":20}`))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(rec, req)
+		return rec
+	}
+
+	decodeProblem(t, put(""), http.StatusPreconditionRequired)
+	decodeProblem(t, put(`"otra"`), http.StatusPreconditionFailed)
+
+	rec := put(etag)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("esperado 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	newETag := rec.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Fatalf("el ETag debería cambiar tras editar: %q -> %q", etag, newETag)
+	}
+
+	// Un segundo operador con el ETag antiguo ya no puede sobrescribir
+	decodeProblem(t, put(etag), http.StatusPreconditionFailed)
+
+	req := httptest.NewRequest(http.MethodDelete, path, nil)
+	req.Header.Set("If-Match", etag)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusPreconditionFailed)
+
+	req = httptest.NewRequest(http.MethodDelete, path, nil)
+	req.Header.Set("If-Match", newETag)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("esperado 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	exportCSV   = "csv"
+	exportJSONL = "jsonl"
+	exportXLSX  = "xlsx"
+	// exportFlushEvery es cada cuántas filas se envía lo escrito al cliente
+	exportFlushEvery = 500
+)
+
+var exportContentTypes = map[string]string{
+	exportCSV:   "text/csv; charset=utf-8",
+	exportJSONL: "application/x-ndjson",
+	exportXLSX:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+var (
+	peopleExportColumns = []string{"id", "name", "age", "photo_url", "status", "cause", "details", "death_time", "created_at", "updated_at"}
+	killsExportColumns  = []string{"id", "person_id", "person_name", "description", "created_at"}
+)
+
+// tableWriter escribe filas de una exportación en un formato concreto
+type tableWriter interface {
+	WriteRow(values []any) error
+	Flush() error
+	Close() error
+}
+
+// HandleExportPeople admite ?as_of= como GET /people: las filas salen de la
+// historia temporal en vez de la tabla actual
+func (s *Server) HandleExportPeople(w http.ResponseWriter, r *http.Request) error {
+	at, err := asOfParam(r)
+	if err != nil {
+		return err
+	}
+	return s.export(w, r, "people", peopleExportColumns, []string{"as_of"}, func(write func([]any) error) error {
+		row := func(p *models.Person) error {
+			dto := p.ToPersonV2Dto()
+			return write([]any{dto.ID, dto.Name, dto.Age, dto.PhotoURL, dto.Status, dto.Cause, dto.Details, dto.DeathTime, dto.CreatedAt, dto.UpdatedAt})
+		}
+		repo := s.PeopleRepository.WithContext(r.Context())
+		if at != nil {
+			return repo.EachAsOf(*at, row)
+		}
+		return repo.Each(row)
+	})
+}
+
+func (s *Server) HandleExportKills(w http.ResponseWriter, r *http.Request) error {
+	return s.export(w, r, "kills", killsExportColumns, nil, func(write func([]any) error) error {
+		return s.KillRepository.WithContext(r.Context()).Each(func(k *models.Kill) error {
+			return write([]any{k.ID, k.PersonId, k.Person.Name, k.Description, k.CreatedAt.Format(time.RFC3339)})
+		})
+	})
+}
+
+// export escribe las filas que produce each en el formato de ?format=
+// (csv por defecto) a medida que salen del cursor. filters son los
+// parámetros de consulta que acepta además de format, los mismos que el
+// listado correspondiente; cualquier otro es un 400. CSV y JSON Lines se
+// envían al cliente cada exportFlushEvery filas; XLSX se genera con el
+// stream writer de excelize y se envía al final, así que solo él puede
+// responder un problem+json si la consulta falla a mitad
+func (s *Server) export(w http.ResponseWriter, r *http.Request, name string, columns, filters []string, each func(write func([]any) error) error) error {
+	for param := range r.URL.Query() {
+		if param != "format" && !slices.Contains(filters, param) {
+			return apiErrorf(CodeBadRequest, "query parameter %q is not supported by the %s export", param, name)
+		}
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportCSV
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		return apiErrorf(CodeBadRequest, "format must be %s, %s or %s", exportCSV, exportJSONL, exportXLSX)
+	}
+
+	writer, err := newTableWriter(format, w, columns)
+	if err != nil {
+		return err
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.WriteHeader(http.StatusOK)
+	}
+
+	rows := 0
+	err = each(func(values []any) error {
+		if format != exportXLSX {
+			start()
+		}
+		if err := writer.WriteRow(values); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 && format != exportXLSX {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			http.NewResponseController(w).Flush()
+		}
+		return nil
+	})
+	if err == nil && format == exportXLSX {
+		// Termina la hoja antes de enviar cabeceras
+		err = writer.Flush()
+	}
+	if err == nil {
+		start()
+		err = writer.Close()
+	}
+	if err != nil {
+		if started {
+			// Las cabeceras ya se enviaron: solo queda cortar la respuesta
+			s.logger.ErrorContext(r.Context(), "export aborted", "export", name, "rows", rows, "error", err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func newTableWriter(format string, w io.Writer, columns []string) (tableWriter, error) {
+	switch format {
+	case exportJSONL:
+		return &jsonlWriter{w: w, columns: columns}, nil
+	case exportXLSX:
+		return newXLSXWriter(w, columns)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: cw}, nil
+}
+
+// exportValue convierte los punteros en su valor o nil
+func exportValue(v any) any {
+	switch value := v.(type) {
+	case *string:
+		if value == nil {
+			return nil
+		}
+		return *value
+	}
+	return v
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		if v = exportValue(v); v != nil {
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	return c.Flush()
+}
+
+// jsonlWriter escribe un objeto JSON por línea con las columnas en orden
+type jsonlWriter struct {
+	w       io.Writer
+	columns []string
+	buf     bytes.Buffer
+}
+
+func (j *jsonlWriter) WriteRow(values []any) error {
+	j.buf.Reset()
+	j.buf.WriteByte('{')
+	for i, column := range j.columns {
+		if i > 0 {
+			j.buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(column)
+		value, err := json.Marshal(exportValue(values[i]))
+		if err != nil {
+			return err
+		}
+		j.buf.Write(key)
+		j.buf.WriteByte(':')
+		j.buf.Write(value)
+	}
+	j.buf.WriteString("}\n")
+	_, err := j.w.Write(j.buf.Bytes())
+	return err
+}
+
+func (j *jsonlWriter) Flush() error { return nil }
+
+func (j *jsonlWriter) Close() error { return nil }
+
+// xlsxWriter usa el stream writer de excelize, que vuelca a un temporal en
+// disco en lugar de mantener la hoja en memoria
+type xlsxWriter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXWriter(w io.Writer, columns []string) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		return nil, err
+	}
+	header := make([]any, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{w: w, file: file, stream: stream, row: 1}, nil
+}
+
+func (x *xlsxWriter) WriteRow(values []any) error {
+	x.row++
+	row := make([]any, len(values))
+	for i, v := range values {
+		row[i] = exportValue(v)
+	}
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	return x.stream.SetRow(cell, row)
+}
+
+// Flush cierra la hoja; después solo queda Close
+func (x *xlsxWriter) Flush() error {
+	return x.stream.Flush()
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+	return x.file.Write(x.w)
+}
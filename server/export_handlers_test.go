@@ -0,0 +1,97 @@
+package server_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestExportPeople(t *testing.T) {
+	s := createTestServer(t)
+	for i := 0; i < 2; i++ {
+		created := createPersonAt(t, s, "/v1/people")
+		s.CancelTaskForTest(int(created["person_id"].(float64)))
+	}
+
+	get := func(format string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/export/people?format="+format, nil)
+		rec := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: esperado 200, got %d: %s", format, rec.Code, rec.Body.String())
+		}
+		if !strings.HasPrefix(rec.Header().Get("Content-Disposition"), "attachment;") {
+			t.Errorf("%s: falta Content-Disposition", format)
+		}
+		return rec
+	}
+
+	records, err := csv.NewReader(get("csv").Body).ReadAll()
+	if err != nil || len(records) != 3 || records[0][1] != "name" || records[1][1] != "Mello" {
+		t.Errorf("CSV inesperado (%v): %v", err, records)
+	}
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(get("jsonl").Body)
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("línea JSON inválida: %s", scanner.Text())
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 || lines[0]["status"] != "pending" || lines[0]["cause"] != nil {
+		t.Errorf("JSON Lines inesperado: %v", lines)
+	}
+
+	book, err := excelize.OpenReader(bytes.NewReader(get("xlsx").Body.Bytes()))
+	if err != nil {
+		t.Fatalf("XLSX inválido: %v", err)
+	}
+	rows, _ := book.GetRows("Sheet1")
+	if len(rows) != 3 || rows[0][0] != "id" {
+		t.Errorf("XLSX inesperado: %v", rows)
+	}
+
+	// as_of exporta la historia, como GET /people
+	for asOf, want := range map[string]int{"2000-01-01T00:00:00Z": 1, time.Now().Add(time.Minute).UTC().Format(time.RFC3339): 3} {
+		records, err := csv.NewReader(get("csv&as_of=" + asOf).Body).ReadAll()
+		if err != nil || len(records) != want {
+			t.Errorf("as_of=%s: esperaba %d filas (%v): %v", asOf, want, err, records)
+		}
+	}
+	// Los parámetros que el listado no admite no se ignoran en silencio
+	rec := serve(s.GetRouter(), http.MethodGet, "/export/people?format=csv&limit=1", "")
+	if p := decodeProblem(t, rec, http.StatusBadRequest); !strings.Contains(p.Detail, "limit") {
+		t.Errorf("detail = %q", p.Detail)
+	}
+}
+
+func TestExportKills(t *testing.T) {
+	s, id := setupKillTestServer(t)
+	defer s.CancelTaskForTest(id)
+	s.DB.Exec("INSERT INTO kills (person_id, description, created_at, updated_at) VALUES (?, 'cuaderno', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)", id)
+
+	req := httptest.NewRequest(http.MethodGet, "/export/kills", nil)
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil || len(records) != 2 || records[1][2] != "Near" || records[1][3] != "cuaderno" {
+		t.Errorf("CSV inesperado (%v): %v", err, records)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/export/kills?format=pdf", nil)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("formato desconocido: esperado 400, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Version se inyecta al compilar con
+// -ldflags "-X backend-avanzada/server.Version=1.2.3"
+var Version = "dev"
+
+const (
+	checkOK          = "ok"
+	checkUnavailable = "unavailable"
+)
+
+// HandleHealthz responde mientras el proceso esté vivo, sin tocar dependencias
+func (s *Server) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, &api.HealthResponseDto{Status: checkOK})
+}
+
+// HandleReadyz comprueba que la base de datos, el directorio de subidas y la
+// cola de tareas estén disponibles; responde 503 si alguno falla
+func (s *Server) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]api.CheckResultDto{
+		"database":  checkResult(s.pingDB(r.Context())),
+		"uploads":   checkResult(checkUploadsWritable()),
+		"taskQueue": checkResult(s.checkTaskQueue()),
+	}
+	resp := &api.ReadinessResponseDto{Status: checkOK, Checks: checks}
+	status := http.StatusOK
+	for _, c := range checks {
+		if c.Status != checkOK {
+			resp.Status = checkUnavailable
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, status, resp)
+}
+
+// HandleStatus expone versión, uptime, tareas pendientes y el pool de la BD
+func (s *Server) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	uptime := time.Since(s.startedAt)
+	resp := &api.StatusResponseDto{
+		Version:       Version,
+		StartedAt:     s.startedAt.Format(time.RFC3339),
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+		PendingTasks:  s.taskQueue.Pending(),
+	}
+	if s.DB != nil {
+		if sqlDB, err := s.DB.DB(); err == nil {
+			stats := sqlDB.Stats()
+			resp.DB = &api.DBPoolStatsDto{
+				MaxOpenConnections: stats.MaxOpenConnections,
+				OpenConnections:    stats.OpenConnections,
+				InUse:              stats.InUse,
+				Idle:               stats.Idle,
+				WaitCount:          stats.WaitCount,
+				WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) pingDB(ctx context.Context) error {
+	if s.DB == nil {
+		return errors.New("database not initialized")
+	}
+	sqlDB, err := s.DB.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+func (s *Server) checkTaskQueue() error {
+	if !s.taskQueue.Running() {
+		return errors.New("task queue stopped")
+	}
+	return nil
+}
+
+// checkUploadsWritable crea y borra un archivo temporal en uploadsDir
+func checkUploadsWritable() error {
+	if err := os.MkdirAll(uploadsDir, os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(uploadsDir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func checkResult(err error) api.CheckResultDto {
+	if err != nil {
+		return api.CheckResultDto{Status: checkUnavailable, Error: err.Error()}
+	}
+	return api.CheckResultDto{Status: checkOK}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
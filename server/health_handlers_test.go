@@ -0,0 +1,66 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthz(t *testing.T) {
+	s := createTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type inesperado: %q", ct)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	s := createTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Status string `json:"status"`
+		Checks map[string]struct {
+			Status string `json:"status"`
+		} `json:"checks"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	for _, name := range []string{"database", "uploads", "taskQueue"} {
+		if body.Checks[name].Status != "ok" {
+			t.Errorf("check %s no está ok: %s", name, rec.Body.String())
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	s := createTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d", rec.Code)
+	}
+	var body map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	for _, key := range []string{"version", "uptime", "pending_tasks", "db"} {
+		if _, ok := body[key]; !ok {
+			t.Errorf("falta %q en la respuesta: %s", key, rec.Body.String())
+		}
+	}
+}
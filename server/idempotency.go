@@ -0,0 +1,176 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader   = "Idempotency-Key"
+	idempotentReplayHeader = "Idempotent-Replayed"
+	// defaultIdempotencyTTL se usa si idempotency_ttl no está configurado
+	defaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyLease es lo que puede durar una reserva en curso: pasado ese
+	// tiempo se da por abandonada (el proceso cayó) y otra petición la toma
+	idempotencyLease     = time.Minute
+	maxIdempotencyKeyLen = 255
+)
+
+// replayedHeaders son las cabeceras de la respuesta original que se repiten
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// idempotent hace que un POST con Idempotency-Key se ejecute una sola vez:
+// los reintentos con el mismo cuerpo reciben la respuesta guardada, un
+// reintento mientras la original sigue en curso recibe 409 y reutilizar la
+// clave con otro cuerpo es un 422. Sin la cabecera no cambia nada.
+// La clave vale para el método y la plantilla de ruta: la misma clave en
+// POST /v1/people y POST /v1/kills/{id} son dos peticiones distintas
+func (s *Server) idempotent(h handlerFunc) handlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			return h(w, r)
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			return apiErrorf(CodeBadRequest, "%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLen)
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadSize))
+		if err != nil {
+			return err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(r, body)
+		key = scopedIdempotencyKey(r, key)
+
+		repo := s.IdempotencyRepository.WithContext(r.Context())
+		now := time.Now()
+		reserved, err := repo.Reserve(&models.IdempotencyKey{
+			Key:         key,
+			RequestHash: hash,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(s.idempotencyTTL()),
+		}, now.Add(-idempotencyLease))
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			return s.replay(w, r, key, hash)
+		}
+		// Si el handler entra en pánico la clave se libera; si no, quedaría en
+		// curso (409) hasta que venciera la reserva
+		defer func() {
+			if p := recover(); p != nil {
+				s.IdempotencyRepository.WithContext(context.WithoutCancel(r.Context())).Release(key)
+				panic(p)
+			}
+		}()
+
+		rec := newRecordingWriter(w)
+		if err := h(rec, r); err != nil {
+			s.HandleError(rec, r, err)
+		}
+		// Los 5xx no se guardan: el cliente debe poder reintentar
+		if rec.status >= http.StatusInternalServerError {
+			return repo.Release(key)
+		}
+		header, _ := json.Marshal(rec.replayHeader())
+		return repo.Complete(key, rec.status, header, rec.body.Bytes())
+	}
+}
+
+// replay responde a un reintento con la respuesta guardada
+func (s *Server) replay(w http.ResponseWriter, r *http.Request, key, hash string) error {
+	record, err := s.IdempotencyRepository.WithContext(r.Context()).FindByKey(key, time.Now())
+	if err != nil {
+		return err
+	}
+	switch {
+	case record == nil:
+		// Caducó o se liberó entre Reserve y FindByKey
+		return apiErrorf(CodeIdempotencyInProgress, "request with this %s is being processed, retry later", IdempotencyKeyHeader)
+	case record.RequestHash != hash:
+		return apiErrorf(CodeIdempotencyKeyReused, "%s was already used with a different request", IdempotencyKeyHeader)
+	case record.Status == 0:
+		return apiErrorf(CodeIdempotencyInProgress, "request with this %s is being processed, retry later", IdempotencyKeyHeader)
+	}
+
+	var header map[string]string
+	json.Unmarshal(record.Header, &header)
+	for name, value := range header {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(idempotentReplayHeader, "true")
+	w.WriteHeader(record.Status)
+	w.Write(record.Body)
+	return nil
+}
+
+func (s *Server) idempotencyTTL() time.Duration {
+	if s.Config.IdempotencyTTL > 0 {
+		return time.Duration(s.Config.IdempotencyTTL) * time.Second
+	}
+	return defaultIdempotencyTTL
+}
+
+// scopedIdempotencyKey guarda la clave del cliente junto al método y la
+// plantilla de ruta; el hash cabe en la columna sea cual sea la ruta
+func scopedIdempotencyKey(r *http.Request, key string) string {
+	h := sha256.Sum256([]byte(r.Method + " " + routeTemplate(r) + "\n" + key))
+	return hex.EncodeToString(h[:])
+}
+
+// requestHash identifica la petición por método, ruta y cuerpo. En multipart
+// se quita el boundary, que cada reintento puede generar distinto
+func requestHash(r *http.Request, body []byte) string {
+	if _, params, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && params["boundary"] != "" {
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), nil)
+	}
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter copia la respuesta para poder guardarla
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func newRecordingWriter(w http.ResponseWriter) *recordingWriter {
+	return &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *recordingWriter) replayHeader() map[string]string {
+	header := map[string]string{}
+	for _, name := range replayedHeaders {
+		if value := w.Header().Get(name); value != "" {
+			header[name] = value
+		}
+	}
+	return header
+}
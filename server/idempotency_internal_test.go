@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend-avanzada/config"
+	"backend-avanzada/models"
+)
+
+func TestIdempotencyReleasesAbandonedKeys(t *testing.T) {
+	s := NewTestServer(&config.Config{
+		Database:                    "postgres",
+		KillDuration:                2,
+		KillDurationWithDescription: 4,
+	})
+	s.ResetDBForTest()
+	created := s.idempotent(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	})
+	post := func(h handlerFunc, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/people", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rec := httptest.NewRecorder()
+		s.handle(h)(rec, req)
+		return rec
+	}
+
+	// Un pánico en el handler libera la clave
+	panics := s.idempotent(func(w http.ResponseWriter, r *http.Request) error {
+		panic("boom")
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("el pánico debería propagarse")
+			}
+		}()
+		post(panics, "panico")
+	}()
+	if rec := post(created, "panico"); rec.Code != http.StatusCreated {
+		t.Errorf("tras el pánico: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Una reserva en curso más vieja que el lease se da por abandonada
+	req := httptest.NewRequest(http.MethodPost, "/people", nil)
+	for key, age := range map[string]time.Duration{"reciente": time.Second, "abandonada": 2 * idempotencyLease} {
+		s.IdempotencyRepository.Reserve(&models.IdempotencyKey{
+			Key:         scopedIdempotencyKey(req, key),
+			RequestHash: requestHash(req, []byte(`{}`)),
+			CreatedAt:   time.Now().Add(-age),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}, time.Now().Add(-idempotencyLease))
+	}
+	if rec := post(created, "reciente"); rec.Code != http.StatusConflict {
+		t.Errorf("reserva reciente: esperado 409, got %d", rec.Code)
+	}
+	if rec := post(created, "abandonada"); rec.Code != http.StatusCreated {
+		t.Errorf("reserva abandonada: esperado 201, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Una clave caducada que la retención aún no borró tampoco bloquea
+	s.IdempotencyRepository.Reserve(&models.IdempotencyKey{
+		Key:         scopedIdempotencyKey(req, "caducada"),
+		RequestHash: requestHash(req, []byte(`{}`)),
+		Status:      http.StatusCreated,
+		CreatedAt:   time.Now().Add(-2 * time.Hour),
+		ExpiresAt:   time.Now().Add(-time.Hour),
+	}, time.Now().Add(-idempotencyLease))
+	if rec := post(created, "caducada"); rec.Code != http.StatusCreated || rec.Header().Get(idempotentReplayHeader) != "" {
+		t.Errorf("clave caducada: esperado 201 sin replay, got %d %s", rec.Code, rec.Body.String())
+	}
+}
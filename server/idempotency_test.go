@@ -0,0 +1,101 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// personForm arma un multipart nuevo (con otro boundary) en cada llamada,
+// como haría un cliente al reintentar
+func personForm(name string) (*bytes.Buffer, string) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("name", name)
+	writer.WriteField("age", "24")
+	file, _ := os.Open("./testdata/light.jpg")
+	defer file.Close()
+	part, _ := writer.CreateFormFile("photo", "light.jpg")
+	io.Copy(part, file)
+	writer.Close()
+	return &buf, writer.FormDataContentType()
+}
+
+func postWithKey(h http.Handler, path, key, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotentCreatePerson(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+
+	body, ct := personForm("Matsuda")
+	first := postWithKey(router, "/v1/people", "alta-matsuda", ct, body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("esperado 201, got %d: %s", first.Code, first.Body.String())
+	}
+	var created map[string]any
+	json.Unmarshal(first.Body.Bytes(), &created)
+	defer s.CancelTaskForTest(int(created["person_id"].(float64)))
+
+	body, ct = personForm("Matsuda")
+	retry := postWithKey(router, "/v1/people", "alta-matsuda", ct, body)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("el reintento debería repetir la respuesta: %d %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("falta Idempotent-Replayed en la respuesta repetida")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/people", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var people []map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &people)
+	if len(people) != 1 {
+		t.Errorf("esperaba 1 persona, hay %d", len(people))
+	}
+
+	// La misma clave con otro cuerpo se rechaza
+	body, ct = personForm("Aizawa")
+	decodeProblem(t, postWithKey(router, "/v1/people", "alta-matsuda", ct, body), http.StatusUnprocessableEntity)
+}
+
+func TestIdempotentCreateKill(t *testing.T) {
+	s, id := setupKillTestServer(t)
+	defer s.CancelTaskForTest(id)
+	router := s.GetRouter()
+	path := "/v1/kills/" + strconv.Itoa(id)
+
+	first := postWithKey(router, path, "kill-"+strconv.Itoa(id), "application/json", strings.NewReader(`{"description":""}`))
+	if first.Code != http.StatusCreated {
+		t.Fatalf("esperado 201, got %d: %s", first.Code, first.Body.String())
+	}
+	// Sin la clave el reintento daría 409 por la tarea en curso
+	retry := postWithKey(router, path, "kill-"+strconv.Itoa(id), "application/json", strings.NewReader(`{"description":""}`))
+	if retry.Code != http.StatusCreated || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("esperado 201 repetido, got %d: %s", retry.Code, retry.Body.String())
+	}
+
+	// La clave es por método y ruta: en POST /v1/people es otra petición
+	body, ct := personForm("Mogi")
+	other := postWithKey(router, "/v1/people", "kill-"+strconv.Itoa(id), ct, body)
+	if other.Code != http.StatusCreated || other.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("esperado 201 nuevo, got %d: %s", other.Code, other.Body.String())
+	}
+	var created map[string]any
+	json.Unmarshal(other.Body.Bytes(), &created)
+	s.CancelTaskForTest(int(created["person_id"].(float64)))
+}
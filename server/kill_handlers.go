@@ -3,81 +3,92 @@ package server
 import (
 	"backend-avanzada/api"
 	"backend-avanzada/models"
+	"backend-avanzada/repository"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
-func (s *Server) HandleKills(w http.ResponseWriter, r *http.Request) {
-	s.handleGetAllKills(w, r)
+func (s *Server) HandleKills(w http.ResponseWriter, r *http.Request) error {
+	return s.handleGetAllKills(w, r)
 }
 
-func (s *Server) HandleKillsWithId(w http.ResponseWriter, r *http.Request) {
-	s.handleCreateKill(w, r)
+func (s *Server) HandleKillsWithId(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodDelete:
+		return s.handleDeleteKill(w, r)
+	default:
+		return s.handleCreateKill(w, r)
+	}
 }
 
-func (s *Server) handleGetAllKills(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+func (s *Server) handleDeleteKill(w http.ResponseWriter, r *http.Request) error {
+	if err := s.revokeKill(r); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) handleGetAllKills(w http.ResponseWriter, r *http.Request) error {
 	result := []*api.KillResponseDto{}
-	kills, err := s.KillRepository.FindAll()
+	kills, err := s.KillRepository.WithContext(r.Context()).FindAll()
 	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+		return err
 	}
 	for _, v := range kills {
 		result = append(result, v.ToKillResponseDto())
 	}
-	response, err := json.Marshal(result)
+	return writeCollectionJSON(w, r, result)
+}
+
+func (s *Server) handleCreateKill(w http.ResponseWriter, r *http.Request) error {
+	kill, _, err := s.createKill(r)
+	if err != nil {
+		return err
+	}
+	result, err := json.Marshal(&api.KillTaskResponseDto{
+		Person: kill.Person.ToPersonResponseDto(),
+		Status: "In progress.",
+	})
 	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+		return err
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(response)
-	s.logger.Info(http.StatusOK, r.URL.Path, start)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(result)
+	return nil
 }
 
-func (s *Server) handleCreateKill(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+// createKill encola la kill de la persona y devuelve la kill pendiente junto
+// con el tiempo que falta para guardarla
+func (s *Server) createKill(r *http.Request) (*models.Kill, time.Duration, error) {
 	var k api.KillRequestDto
 	var duration time.Duration
-	err := json.NewDecoder(r.Body).Decode(&k)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	if err := decodeJSON(r, &k); err != nil {
+		return nil, 0, err
 	}
-	vars := mux.Vars(r)
-	id, err := strconv.ParseInt(vars["id"], 10, 32)
+	id, err := pathID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		return nil, 0, err
 	}
-	_, exists := s.taskQueue.tasks[int(id)]
-	if exists {
-		s.HandleError(w, http.StatusConflict, r.URL.Path, fmt.Errorf("task with id %d is already in progress", id))
-		return
+	if s.taskQueue.HasTask(id) {
+		return nil, 0, apiErrorf(CodeTaskInProgress, "task with id %d is already in progress", id)
 	}
-	person, err := s.PeopleRepository.FindById(int(id))
-	if person == nil && err == nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
+	person, err := s.findPerson(r.Context(), id)
+	if err != nil {
+		return nil, 0, err
 	}
+	kill, err := s.KillRepository.WithContext(r.Context()).FindById(id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, 0, err
 	}
-	kill, err := s.KillRepository.FindById(int(id))
 	if kill != nil {
-		w.WriteHeader(http.StatusConflict)
-		return
-	}
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, 0, apiErrorf(CodeKillExists, "person %d already has a kill", id)
 	}
 	kill = &models.Kill{
 		Description: k.Description,
@@ -89,24 +100,48 @@ func (s *Server) handleCreateKill(w http.ResponseWriter, r *http.Request) {
 	} else {
 		duration = time.Duration(s.Config.KillDurationWithDescription) * time.Second
 	}
-	killFunc := func(k *models.Kill) error {
-		_, err := s.KillRepository.Save(k)
-		if err != nil {
-			return err
-		}
+	ctx := r.Context()
+	err = s.UnitOfWork.WithContext(ctx).Do(func(tx *repository.Tx) error {
+		return s.scheduleTask(ctx, tx, TaskKill, person.ID, duration, kill.Description)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return kill, duration, nil
+}
+
+// revokeKill revoca la kill de la persona del path: cancela la que aún está
+// en cola o borra la ya guardada. Sin ninguna de las dos devuelve
+// kill_not_found
+func (s *Server) revokeKill(r *http.Request) error {
+	id, err := pathID(r)
+	if err != nil {
+		return err
+	}
+	cancelled, err := s.TaskRepository.WithContext(r.Context()).Delete(uint(id), string(TaskKill))
+	if err != nil {
+		return err
+	}
+	if cancelled {
+		s.taskQueue.CancelTaskOfKind(r.Context(), id, TaskKill)
 		return nil
 	}
-	s.taskQueue.StartTask(int(person.ID), duration, killFunc, kill)
-	result, err := json.Marshal(&api.KillTaskResponseDto{
-		Person: person.ToPersonResponseDto(),
-		Status: "In progress.",
-	})
+	notFound := apiErrorf(CodeKillNotFound, "person %d has no kill", id)
+	kill, err := s.KillRepository.WithContext(r.Context()).FindById(id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return err
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	w.Write(result)
-	s.logger.Info(http.StatusCreated, r.URL.Path, start)
+	if kill == nil {
+		return notFound
+	}
+	err = s.UnitOfWork.WithContext(r.Context()).Do(func(tx *repository.Tx) error {
+		if err := tx.Kills.Delete(kill); err != nil {
+			return err
+		}
+		return tx.Events.Record(kill.PersonId, models.EventKillRevoked, map[string]any{"description": kill.Description})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFound
+	}
+	return err
 }
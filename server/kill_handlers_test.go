@@ -2,9 +2,11 @@ package server_test
 
 import (
 	"backend-avanzada/config"
+	"backend-avanzada/models"
 	"backend-avanzada/server"
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -22,8 +24,7 @@ func setupKillTestServer(t *testing.T) (*server.Server, int) {
 		KillDurationWithDescription: 4,
 	}
 	s := server.NewTestServer(cfg)
-	s.DB.Exec("DELETE FROM kills")
-	s.DB.Exec("DELETE FROM people")
+	resetDB(s)
 
 	// Crear persona con foto
 	var buf bytes.Buffer
@@ -102,3 +103,42 @@ func TestGetAllKillsEmptyInitially(t *testing.T) {
 		t.Errorf("respuesta no válida: %s", rec.Body.String())
 	}
 }
+
+func TestDeleteKill(t *testing.T) {
+	s, id := setupKillTestServer(t)
+	router := s.GetRouter()
+	path := "/kills/" + strconv.Itoa(id)
+
+	// Una kill en cola se revoca cancelando su tarea
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{ "description": "" }`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("esperado 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(router, http.MethodDelete, path, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("revocar en cola: %d %s", rec.Code, rec.Body.String())
+	}
+	if p := decodeProblem(t, serve(router, http.MethodDelete, path, ""), http.StatusNotFound); p.Code != "kill_not_found" {
+		t.Errorf("code = %q", p.Code)
+	}
+
+	// Una kill guardada se borra; después la persona admite otra
+	if _, err := s.KillRepository.Save(&models.Kill{PersonId: uint(id), Description: "Kira"}); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	if rec := serve(router, http.MethodDelete, "/v2"+path, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("revocar guardada: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(router, http.MethodGet, "/v2/kills", ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("la kill revocada sigue listada: %s", rec.Body.String())
+	}
+	if kill, err := s.KillRepository.FindById(id); err != nil || kill != nil {
+		t.Errorf("FindById() = %+v, %v", kill, err)
+	}
+	events := getTimeline(t, router, fmt.Sprintf("/v1/people/%d/timeline", id))
+	if last := events[len(events)-1]; last != "kill_revoked/anonymous" {
+		t.Errorf("último evento: %s", last)
+	}
+}
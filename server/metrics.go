@@ -0,0 +1,118 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// unmatchedRoute es la ruta de las peticiones que mux no asocia a
+	// ninguna, así una URL inventada no crea una serie nueva
+	unmatchedRoute = "unmatched"
+)
+
+// Metrics agrupa los colectores de Prometheus del servidor. Cada servidor tiene
+// su propio registro para poder crear varios en las pruebas
+type Metrics struct {
+	registry      *prometheus.Registry
+	httpRequests  *prometheus.CounterVec
+	httpDuration  *prometheus.HistogramVec
+	deaths        *prometheus.CounterVec
+	taskErrors    prometheus.Counter
+	uploadedBytes prometheus.Counter
+}
+
+func NewMetrics(tq *TaskQueue) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "deathnote_http_requests_total",
+			Help: "Peticiones HTTP atendidas por ruta, método y status.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "deathnote_http_request_duration_seconds",
+			Help:    "Latencia de las peticiones HTTP por ruta y método.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		deaths: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "deathnote_deaths_total",
+			Help: "Muertes aplicadas por la cola de tareas según el tipo de tarea (heart_attack o death).",
+		}, []string{"cause"}),
+		taskErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "deathnote_task_errors_total",
+			Help: "Tareas asíncronas que terminaron con error.",
+		}),
+		uploadedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "deathnote_upload_bytes_total",
+			Help: "Bytes de fotos subidas y guardadas en disco.",
+		}),
+	}
+	m.registry.MustRegister(
+		m.httpRequests,
+		m.httpDuration,
+		m.deaths,
+		m.taskErrors,
+		m.uploadedBytes,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "deathnote_tasks_pending",
+			Help: "Tareas encoladas en TaskQueue que aún no se ejecutan.",
+		}, func() float64 { return float64(tq.Pending()) }),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler expone el registro en formato de texto de Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Middleware mide cada petición usando la plantilla de ruta de mux para no
+// generar una serie por cada id. Envuelve al router entero, como el access
+// log, para contar también los 404 y 405, todos con la ruta "unmatched"
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := newResponseWriter(w)
+		r, route := withAccessRoute(r)
+		next.ServeHTTP(rw, r)
+
+		m.httpRequests.WithLabelValues(route.template, r.Method, strconv.Itoa(rw.status)).Inc()
+		m.httpDuration.WithLabelValues(route.template, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// deathTask envuelve una tarea para contar sus errores y, si aplicó una
+// muerte, la muerte con la causa kind. Una tarea sobre una persona que ya
+// no existe no aplica nada y no cuenta
+func (m *Metrics) deathTask(kind TaskKind, task func(ctx context.Context, k *models.Kill) (bool, error)) func(ctx context.Context, k *models.Kill) error {
+	return func(ctx context.Context, k *models.Kill) error {
+		died, err := task(ctx, k)
+		if err != nil {
+			m.taskErrors.Inc()
+			return err
+		}
+		if died {
+			m.deaths.WithLabelValues(string(kind)).Inc()
+		}
+		return nil
+	}
+}
+
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return unmatchedRoute
+}
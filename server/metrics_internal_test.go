@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"backend-avanzada/logger"
+	"backend-avanzada/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsDeathTask(t *testing.T) {
+	m := NewMetrics(NewTaskQueue(logger.NewLogger()))
+
+	died := m.deathTask(TaskHeartAttack, func(ctx context.Context, k *models.Kill) (bool, error) { return true, nil })
+	gone := m.deathTask(TaskDeath, func(ctx context.Context, k *models.Kill) (bool, error) { return false, nil })
+	kill := m.deathTask(TaskKill, func(ctx context.Context, k *models.Kill) (bool, error) { return false, nil })
+	failing := m.deathTask(TaskDeath, func(ctx context.Context, k *models.Kill) (bool, error) { return false, errors.New("boom") })
+
+	died(context.Background(), nil)
+	died(context.Background(), nil)
+	gone(context.Background(), nil)
+	kill(context.Background(), nil)
+	failing(context.Background(), nil)
+
+	if got := testutil.ToFloat64(m.deaths.WithLabelValues(string(TaskHeartAttack))); got != 2 {
+		t.Errorf("muertes por ataque al corazón = %v, esperaba 2", got)
+	}
+	// Ni la persona que ya no existía ni la kill guardada cuentan
+	if got := testutil.CollectAndCount(m.deaths); got != 1 {
+		t.Errorf("series de muertes = %d, esperaba solo heart_attack", got)
+	}
+	if got := testutil.ToFloat64(m.taskErrors); got != 1 {
+		t.Errorf("errores de tareas = %v, esperaba 1", got)
+	}
+}
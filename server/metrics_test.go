@@ -0,0 +1,63 @@
+package server_test
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("name", "Ryuk")
+	writer.WriteField("age", "99")
+	file, _ := os.Open("./testdata/light.jpg")
+	defer file.Close()
+	part, _ := writer.CreateFormFile("photo", "light.jpg")
+	io.Copy(part, file)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/people", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creación falló: %s", rec.Body.String())
+	}
+
+	// Las rutas que no existen cuentan, con una sola serie
+	for _, path := range []string{"/no-existe/1", "/no-existe/2"} {
+		decodeProblem(t, serve(router, http.MethodGet, path, ""), http.StatusNotFound)
+	}
+	decodeProblem(t, serve(router, http.MethodDelete, "/metrics", ""), http.StatusMethodNotAllowed)
+
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d", rec.Code)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`deathnote_http_requests_total{method="POST",route="/people",status="201"} 1`,
+		`deathnote_http_request_duration_seconds_bucket{method="POST",route="/people"`,
+		"deathnote_tasks_pending 1",
+		"deathnote_upload_bytes_total",
+		"deathnote_task_errors_total 0",
+		`deathnote_http_requests_total{method="GET",route="unmatched",status="404"} 2`,
+		`deathnote_http_requests_total{method="DELETE",route="unmatched",status="405"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("falta %q en /metrics", want)
+		}
+	}
+}
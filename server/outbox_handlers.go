@@ -0,0 +1,69 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/outbox"
+	"backend-avanzada/repository"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultOutboxInterval es la pausa entre pasadas del relay si
+// outbox_interval no está configurado
+const defaultOutboxInterval = 5 * time.Second
+
+// outboxSinks son las suscripciones de POST /webhooks y los sinks
+// configurados: un webhook y un fichero JSON Lines
+func (s *Server) outboxSinks() []outbox.Sink {
+	sinks := []outbox.Sink{s.webhooks}
+	if s.Config.OutboxWebhookURL != "" {
+		sinks = append(sinks, outbox.NewWebhookSink(s.Config.OutboxWebhookURL))
+	}
+	if s.Config.OutboxFile != "" {
+		sinks = append(sinks, outbox.NewFileSink(s.Config.OutboxFile))
+	}
+	return sinks
+}
+
+func (s *Server) outboxInterval() time.Duration {
+	if s.Config.OutboxInterval <= 0 {
+		return defaultOutboxInterval
+	}
+	return time.Duration(s.Config.OutboxInterval) * time.Second
+}
+
+// HandleOutbox lista los mensajes del outbox, del más reciente al más
+// antiguo, con su estado en cada sink; filtra por status y type
+func (s *Server) HandleOutbox(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	filter := repository.OutboxFilter{
+		Status: q.Get("status"),
+		Topic:  q.Get("type"),
+		Limit:  defaultAuditMax,
+	}
+	switch filter.Status {
+	case "", models.OutboxPending, models.OutboxDelivered, models.OutboxFailed:
+	default:
+		return apiErrorf(CodeBadRequest, "status must be %q, %q or %q", models.OutboxPending, models.OutboxDelivered, models.OutboxFailed)
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return apiErrorf(CodeBadRequest, "limit must be between 1 and %d", maxAuditLimit)
+		}
+		filter.Limit = limit
+	}
+
+	messages, err := s.OutboxRepository.WithContext(r.Context()).List(filter)
+	if err != nil {
+		return err
+	}
+	result := make([]*api.OutboxMessageDto, 0, len(messages))
+	for _, m := range messages {
+		result = append(result, m.ToOutboxMessageDto())
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
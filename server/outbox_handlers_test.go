@@ -0,0 +1,126 @@
+package server_test
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/outbox"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+// flakySink falla mientras down sea true
+type flakySink struct {
+	down  bool
+	calls atomic.Int32
+}
+
+func (f *flakySink) Name() string {
+	return "flaky"
+}
+
+func (f *flakySink) Deliver(context.Context, *api.NotificationDto) error {
+	f.calls.Add(1)
+	if f.down {
+		return errors.New("receiver down")
+	}
+	return nil
+}
+
+func listOutbox(t *testing.T, h http.Handler, query string) []api.OutboxMessageDto {
+	rec := serve(h, http.MethodGet, "/outbox"+query, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /outbox: %d %s", rec.Code, rec.Body.String())
+	}
+	var messages []api.OutboxMessageDto
+	json.Unmarshal(rec.Body.Bytes(), &messages)
+	return messages
+}
+
+func TestOutboxDeliversDeathsAndKills(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+	person, err := s.PeopleRepository.Save(&models.Person{Name: "L", Age: 25})
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if _, err := s.KillRepository.Save(&models.Kill{PersonId: person.ID, Description: "Kira"}); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	if _, err := s.PeopleRepository.MarkHeartAttack(person.ID); err != nil {
+		t.Fatalf("MarkHeartAttack() error: %v", err)
+	}
+
+	// Escritos con el alta, la kill y la muerte
+	if pending := listOutbox(t, router, "?status=pending"); len(pending) != 3 || pending[0].Type != models.TopicPersonDied ||
+		pending[1].Type != models.TopicKillCreated || pending[2].Type != models.TopicPersonCreated {
+		t.Fatalf("pendientes: %+v", pending)
+	}
+
+	received := make(chan *api.NotificationDto, 10)
+	flaky := &flakySink{down: true}
+	s.Outbox.AddSink(outbox.NewChannelSink("channel", received))
+	s.Outbox.AddSink(flaky)
+	if delivered, err := s.Outbox.Flush(context.Background()); err != nil || delivered != 0 {
+		t.Fatalf("Flush() = %d, %v", delivered, err)
+	}
+	if len(received) != 3 {
+		t.Fatalf("el canal recibió %d", len(received))
+	}
+	var died api.PersonNotificationDto
+	<-received
+	<-received
+	json.Unmarshal((<-received).Data, &died)
+	if died.PersonID != person.ID || died.Cause == nil || died.DeathTime == "" {
+		t.Errorf("person.died: %+v", died)
+	}
+	messages := listOutbox(t, router, "")
+	// Sin suscripciones el sink de webhooks no tiene nada que enviar
+	if m := messages[0]; m.Status != models.OutboxPending || m.Attempts != 1 || m.NextAttemptAt == nil || len(m.Deliveries) != 3 ||
+		m.Deliveries[0].Status != models.OutboxDelivered || m.Deliveries[1].Status != models.OutboxPending || m.Deliveries[2].Status != models.OutboxDelivered {
+		t.Fatalf("tras el primer intento: %+v", m)
+	}
+
+	// El reintento solo va al sink que falló
+	flaky.down = false
+	s.DB.Model(&models.OutboxMessage{}).Where("1 = 1").Update("next_attempt_at", person.CreatedAt)
+	s.DB.Model(&models.OutboxDelivery{}).Where("1 = 1").Update("next_attempt_at", person.CreatedAt)
+	if delivered, err := s.Outbox.Flush(context.Background()); err != nil || delivered != 3 {
+		t.Fatalf("Flush() = %d, %v", delivered, err)
+	}
+	if len(received) != 0 {
+		t.Errorf("el canal recibió %d duplicados", len(received))
+	}
+	if delivered := listOutbox(t, router, "?status=delivered"); len(delivered) != 3 || delivered[0].DeliveredAt == nil {
+		t.Errorf("entregados: %+v", delivered)
+	}
+	decodeProblem(t, serve(router, http.MethodGet, "/outbox?status=lost", ""), http.StatusBadRequest)
+}
+
+func TestOutboxFailingSinkDoesNotStallOthers(t *testing.T) {
+	s := createTestServer(t)
+	for _, name := range []string{"Naomi", "Raye", "Watari"} {
+		if _, err := s.PeopleRepository.Save(&models.Person{Name: name, Age: 30}); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+	}
+	received := make(chan *api.NotificationDto, 10)
+	flaky := &flakySink{down: true}
+	s.Outbox.AddSink(flaky)
+	s.Outbox.AddSink(outbox.NewChannelSink("channel", received))
+	if _, err := s.Outbox.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	// El sink caído se intenta una vez por pasada; los demás reciben todo
+	if len(received) != 3 || flaky.calls.Load() != 1 {
+		t.Fatalf("canal %d, intentos del sink caído %d", len(received), flaky.calls.Load())
+	}
+	for _, m := range listOutbox(t, s.GetRouter(), "") {
+		if m.Status != models.OutboxPending {
+			t.Errorf("mensaje %d: %s", m.ID, m.Status)
+		}
+	}
+}
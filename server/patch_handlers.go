@@ -0,0 +1,247 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// personPatchDoc es el documento sobre el que se aplica el parche: solo los
+// campos que PATCH puede modificar. La foto se cambia con su propio endpoint
+type personPatchDoc struct {
+	Name    string  `json:"name"`
+	Age     int     `json:"age"`
+	Cause   *string `json:"cause"`
+	Details *string `json:"details"`
+}
+
+func (s *Server) handlePatchPerson(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.patchPerson(r)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, http.StatusOK, person, person.ToPersonResponseDto())
+	return nil
+}
+
+// patchPerson aplica un JSON Merge Patch (RFC 7396) o un JSON Patch
+// (RFC 6902) a la persona, valida las reglas de cada campo y guarda solo lo
+// que cambió, siempre que If-Match coincida con la versión actual. Cambiar
+// la causa o los detalles reprograma la muerte igual que
+// POST /people/{id}/cause y /details
+func (s *Server) patchPerson(r *http.Request) (*models.Person, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchContentType && mediaType != jsonPatchContentType {
+		return nil, apiErrorf(CodeUnsupportedMedia, "PATCH accepts %s or %s", mergePatchContentType, jsonPatchContentType)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	person, err := s.findPerson(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIfMatch(r, personETag(person)); err != nil {
+		return nil, err
+	}
+
+	current := personPatchDoc{Name: person.Name, Age: person.Age, Cause: person.Cause, Details: person.Details}
+	original, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := applyPatch(mediaType, original, body)
+	if err != nil {
+		return nil, err
+	}
+	next, err := decodePatchedPerson(patched)
+	if err != nil {
+		return nil, err
+	}
+
+	updates, fieldErrors := personPatchUpdates(person, current, next)
+	if len(fieldErrors) > 0 {
+		return nil, &APIError{
+			Code:   CodeUnprocessable,
+			Detail: fmt.Sprintf("%d field(s) cannot be patched", len(fieldErrors)),
+			Errors: fieldErrors,
+		}
+	}
+	if len(updates) == 0 {
+		return person, nil
+	}
+	ctx := r.Context()
+	err = s.UnitOfWork.WithContext(ctx).Do(func(tx *repository.Tx) error {
+		updated, err := tx.People.UpdateIfVersion(person.ID, person.Version, updates)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return staleVersion(id)
+		}
+		// Los detalles se envían después de la causa, así que mandan ellos
+		switch {
+		case !equalStrings(current.Details, next.Details):
+			err = s.scheduleDeath(ctx, tx, id, time.Duration(s.Config.KillDuration)*time.Second)
+		case !equalStrings(current.Cause, next.Cause):
+			err = s.scheduleDeath(ctx, tx, id, time.Duration(s.Config.KillDurationWithDescription)*time.Second)
+		}
+		if err != nil {
+			return err
+		}
+		return recordEdits(tx.Events, person.ID, current, next)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.findPerson(ctx, id)
+}
+
+// applyPatch aplica el cuerpo según su tipo. Un parche mal formado es un 400;
+// uno bien formado que no se puede aplicar (test fallido, ruta inexistente)
+// es un 422
+func applyPatch(mediaType string, original, body []byte) ([]byte, error) {
+	if mediaType == mergePatchContentType {
+		patched, err := jsonpatch.MergePatch(original, body)
+		if err != nil {
+			return nil, wrapAPIError(CodeMalformedBody, err)
+		}
+		return patched, nil
+	}
+	patch, err := jsonpatch.DecodePatch(body)
+	if err != nil {
+		return nil, wrapAPIError(CodeMalformedBody, err)
+	}
+	patched, err := patch.Apply(original)
+	if err != nil {
+		return nil, wrapAPIError(CodeUnprocessable, err)
+	}
+	return patched, nil
+}
+
+// decodePatchedPerson rechaza campos añadidos por el parche, campos
+// obligatorios eliminados y valores del tipo incorrecto
+func decodePatchedPerson(patched []byte) (personPatchDoc, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patched, &fields); err != nil {
+		return personPatchDoc{}, wrapAPIError(CodeUnprocessable, err)
+	}
+	var fieldErrors []api.FieldError
+	for name := range fields {
+		switch name {
+		case "name", "age", "cause", "details":
+		default:
+			fieldErrors = append(fieldErrors, api.FieldError{In: "body", Field: name, Message: "field cannot be patched"})
+		}
+	}
+	for _, name := range []string{"name", "age"} {
+		if raw, ok := fields[name]; !ok || string(raw) == "null" {
+			fieldErrors = append(fieldErrors, api.FieldError{In: "body", Field: name, Message: "field cannot be removed"})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return personPatchDoc{}, &APIError{
+			Code:   CodeUnprocessable,
+			Detail: fmt.Sprintf("%d field(s) cannot be patched", len(fieldErrors)),
+			Errors: fieldErrors,
+		}
+	}
+
+	var next personPatchDoc
+	if err := json.Unmarshal(patched, &next); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return personPatchDoc{}, &APIError{
+				Code:   CodeUnprocessable,
+				Detail: "1 field(s) cannot be patched",
+				Errors: []api.FieldError{{In: "body", Field: typeErr.Field, Message: fmt.Sprintf("must be %s", typeErr.Type)}},
+				Err:    err,
+			}
+		}
+		return personPatchDoc{}, wrapAPIError(CodeUnprocessable, err)
+	}
+	return next, nil
+}
+
+// deathScheduled indica si la muerte de la persona ya tiene forma: con causa
+// o detalles escritos, o ya muerta. Desde entonces el nombre no cambia
+func deathScheduled(person *models.Person) bool {
+	return person.DeathTime != nil || person.Cause != nil || person.Details != nil
+}
+
+// personPatchUpdates compara el documento antes y después de la escritura
+// (PATCH o PUT) y devuelve las columnas a actualizar junto con las reglas
+// incumplidas:
+//   - nombre: no cambia una vez programada la muerte (deathScheduled)
+//   - edad, causa y detalles: no cambian una vez muerta
+func personPatchUpdates(person *models.Person, current, next personPatchDoc) (map[string]interface{}, []api.FieldError) {
+	dead := person.DeathTime != nil
+	scheduled := deathScheduled(person)
+	updates := map[string]interface{}{}
+	var fieldErrors []api.FieldError
+	reject := func(field, message string) {
+		fieldErrors = append(fieldErrors, api.FieldError{In: "body", Field: field, Message: message})
+	}
+
+	if next.Name != current.Name {
+		switch {
+		case scheduled:
+			reject("name", "name cannot change once the death is scheduled")
+		case next.Name == "":
+			reject("name", "name cannot be empty")
+		default:
+			updates["name"] = next.Name
+		}
+	}
+	if next.Age != current.Age {
+		switch {
+		case dead:
+			reject("age", "age cannot change after death")
+		case next.Age < 1:
+			reject("age", "age must be at least 1")
+		default:
+			updates["age"] = next.Age
+		}
+	}
+	if !equalStrings(current.Cause, next.Cause) {
+		if dead {
+			reject("cause", "cause cannot change after death")
+		} else {
+			updates["cause"] = next.Cause
+		}
+	}
+	if !equalStrings(current.Details, next.Details) {
+		if dead {
+			reject("details", "details cannot change after death")
+		} else {
+			updates["details"] = next.Details
+		}
+	}
+	return updates, fieldErrors
+}
+
+func equalStrings(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
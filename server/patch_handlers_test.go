@@ -0,0 +1,124 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// patchPerson envía el PATCH con el ETag actual en If-Match
+func patchPerson(t *testing.T, h http.Handler, path, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("If-Match", currentETag(t, h, path))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPatchPersonMergePatch(t *testing.T) {
+	s := createTestServer(t)
+	created := createPersonAt(t, s, "/v1/people")
+	id := int(created["person_id"].(float64))
+	defer s.CancelTaskForTest(id)
+	path := "/v1/people/" + strconv.Itoa(id)
+
+	// Con solo el ataque al corazón en cola el nombre aún se puede cambiar
+	rec := patchPerson(t, s.GetRouter(), path, "application/merge-patch+json", `{"name":"Near"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// La edad y la causa también
+	rec = patchPerson(t, s.GetRouter(), path, "application/merge-patch+json", `{"age":30,"cause":"accidente"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var person map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &person)
+	if person["age"] != float64(30) || person["cause"] != "accidente" || person["name"] != "Near" {
+		t.Errorf("persona inesperada: %v", person)
+	}
+	if person["status"] == nil || person["photo_url"] == nil {
+		t.Errorf("PATCH debe devolver el DTO completo: %v", person)
+	}
+
+	// Con la causa escrita el nombre ya no
+	rec = patchPerson(t, s.GetRouter(), path, "application/merge-patch+json", `{"name":"Mihael"}`)
+	problem := decodeProblem(t, rec, http.StatusUnprocessableEntity)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "name" {
+		t.Errorf("errores inesperados: %+v", problem.Errors)
+	}
+}
+
+func TestPatchPersonJSONPatch(t *testing.T) {
+	s := createTestServer(t)
+	created := createPersonAt(t, s, "/v2/people")
+	id := int(created["id"].(float64))
+	s.CancelTaskForTest(id)
+	path := "/v2/people/" + strconv.Itoa(id)
+
+	rec := patchPerson(t, s.GetRouter(), path, "application/json-patch+json",
+		`[{"op":"test","path":"/name","value":"Mello"},{"op":"replace","path":"/name","value":"Mihael"}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var person map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &person)
+	if person["name"] != "Mihael" {
+		t.Errorf("nombre no actualizado: %v", person)
+	}
+
+	// Un test que falla no modifica nada
+	rec = patchPerson(t, s.GetRouter(), path, "application/json-patch+json",
+		`[{"op":"test","path":"/name","value":"Mello"},{"op":"replace","path":"/age","value":40}]`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("esperado 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Solo se admiten los campos editables
+	rec = patchPerson(t, s.GetRouter(), path, "application/json-patch+json",
+		`[{"op":"add","path":"/photo_url","value":"x.jpg"},{"op":"remove","path":"/age"}]`)
+	if problem := decodeProblem(t, rec, http.StatusUnprocessableEntity); len(problem.Errors) != 2 {
+		t.Errorf("esperaba 2 errores: %+v", problem.Errors)
+	}
+
+	rec = patchPerson(t, s.GetRouter(), path, "application/json", `{"age":40}`)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("esperado 415, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEditPersonFollowsPatchRules(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+	created := createPersonAt(t, s, "/v1/people")
+	id := int(created["person_id"].(float64))
+	defer s.CancelTaskForTest(id)
+	path := "/v1/people/" + strconv.Itoa(id)
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", currentETag(t, router, path))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := put(`{"name":"Mihael","age":20}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("esperado 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := patchPerson(t, router, path, "application/merge-patch+json", `{"cause":"accidente"}`); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH cause: %d %s", rec.Code, rec.Body.String())
+	}
+	problem := decodeProblem(t, put(`{"name":"Mello","age":20}`), http.StatusUnprocessableEntity)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "name" {
+		t.Errorf("errores inesperados: %+v", problem.Errors)
+	}
+	if rec := put(`{"name":"Mihael","age":21}`); rec.Code != http.StatusAccepted {
+		t.Errorf("la edad sigue siendo editable: %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -25,12 +25,16 @@ func createTestServer(t *testing.T) *server.Server {
 	}
 	s := server.NewTestServer(cfg)
 
-	// Limpiar tablas
-	s.DB.Exec("DELETE FROM kills")
-	s.DB.Exec("DELETE FROM people")
+	resetDB(s)
 	return s
 }
 
+// resetDB vacía todas las tablas que crea AutoMigrate; lo usan todos los
+// fixtures para que el resultado no dependa del orden de las pruebas
+func resetDB(s *server.Server) {
+	s.ResetDBForTest()
+}
+
 func TestCreateAndGetPerson(t *testing.T) {
 	s := createTestServer(t)
 
@@ -133,3 +137,34 @@ func TestGetAllKills(t *testing.T) {
 		t.Errorf("respuesta no válida: %s", rec.Body.String())
 	}
 }
+
+func TestAddCauseAndDetailsUnknownPerson(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+
+	for _, path := range []string{"/v1/people/999999/cause", "/v1/people/999999/details", "/v2/people/999999/cause"} {
+		field := filepath.Base(path)
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"`+field+`":"accidente"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if p := decodeProblem(t, rec, http.StatusNotFound); p.Code != "person_not_found" {
+			t.Errorf("%s: code = %q", path, p.Code)
+		}
+	}
+
+	// Ni línea de tiempo huérfana ni muerte programada
+	var events int64
+	s.DB.Table("person_events").Where("person_id = ?", 999999).Count(&events)
+	if events != 0 {
+		t.Errorf("%d eventos para una persona inexistente", events)
+	}
+	rec := serve(router, http.MethodGet, "/status", "")
+	var status struct {
+		PendingTasks int `json:"pending_tasks"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if status.PendingTasks != 0 {
+		t.Errorf("pending_tasks = %d", status.PendingTasks)
+	}
+}
@@ -3,50 +3,47 @@ package server
 import (
 	"backend-avanzada/api"
 	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 )
 
-func (s *Server) HandlePeople(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HandlePeople(w http.ResponseWriter, r *http.Request) error {
 	switch r.Method {
 	case http.MethodGet:
-		s.handleGetAllPeople(w, r)
-		return
+		return s.handleGetAllPeople(w, r)
 	case http.MethodPost:
-		s.handleCreatePerson(w, r)
-		return
+		return s.handleCreatePerson(w, r)
 	}
+	return nil
 }
 
-func (s *Server) HandlePeopleWithId(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HandlePeopleWithId(w http.ResponseWriter, r *http.Request) error {
 	switch r.Method {
 	case http.MethodGet:
-		s.handleGetPersonById(w, r)
-		return
+		return s.handleGetPersonById(w, r)
 	case http.MethodPut:
-		s.handleEditPerson(w, r)
-		return
+		return s.handleEditPerson(w, r)
+	case http.MethodPatch:
+		return s.handlePatchPerson(w, r)
 	case http.MethodDelete:
-		s.handleDeletePerson(w, r)
-		return
+		return s.handleDeletePerson(w, r)
 	}
+	return nil
 }
 
-func (s *Server) handleGetAllPeople(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-
-	people, err := s.PeopleRepository.FindAll()
+func (s *Server) handleGetAllPeople(w http.ResponseWriter, r *http.Request) error {
+	people, err := s.listPeople(r)
 	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+		return err
 	}
 
 	// Convertimos cada modelo a su DTO (incluye foto, estado, causa, detalles, muerte)
@@ -57,30 +54,21 @@ func (s *Server) handleGetAllPeople(w http.ResponseWriter, r *http.Request) {
 
 	response, err := json.Marshal(result)
 	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+		return err
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(response)
-	s.logger.Info(http.StatusOK, r.URL.Path, start)
+	return nil
 }
 
-func (s *Server) handleGetPersonById(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	vars := mux.Vars(r)
-	id, err := strconv.ParseInt(vars["id"], 10, 32)
+func (s *Server) handleGetPersonById(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r)
 	if err != nil {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, err)
-		return
-	}
-	p, err := s.PeopleRepository.FindById(int(id))
-	if p == nil && err == nil {
-		s.HandleError(w, http.StatusNotFound, r.URL.Path, fmt.Errorf("person with id %d not found", id))
-		return
+		return err
 	}
+	p, err := s.findPersonAsOf(r, id)
 	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+		return err
 	}
 	resp := &api.PersonResponseDto{
 		ID:            int(p.ID),
@@ -88,118 +76,87 @@ func (s *Server) handleGetPersonById(w http.ResponseWriter, r *http.Request) {
 		Edad:          p.Age,
 		FechaCreacion: p.CreatedAt.String(),
 	}
+	if notModified(w, r, personETag(p)) {
+		return nil
+	}
 	response, err := json.Marshal(resp)
 	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+		return err
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(response)
-	s.logger.Info(http.StatusOK, r.URL.Path, start)
+	return nil
 }
 
-func (s *Server) handleCreatePerson(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+func (s *Server) handleCreatePerson(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.createPerson(w, r)
+	if err != nil {
+		return err
+	}
 
-	// 1) Límite de tamaño (por ejemplo 10 MB)
-	r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+	// 5) Responder
+	resp := person.ToPersonResponseDto()
+	data, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", personETag(person))
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+	return nil
+}
 
-	// 2) Parsear multipart
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("error parsing form: %w", err))
-		return
+// createPerson guarda la foto y la persona y encola la muerte inicial; lo
+// comparten todas las versiones de POST /people
+func (s *Server) createPerson(w http.ResponseWriter, r *http.Request) (*models.Person, error) {
+	// 1) Límite de tamaño y multipart
+	if err := parsePhotoForm(w, r); err != nil {
+		return nil, err
 	}
 	name := r.FormValue("name")
 	ageStr := r.FormValue("age")
 	if name == "" || ageStr == "" {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("name and age are required"))
-		return
+		return nil, apiErrorf(CodeBadRequest, "name and age are required")
 	}
 	age, err := strconv.Atoi(ageStr)
 	if err != nil || age <= 0 {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("invalid age"))
-		return
-	}
-
-	// 3) Obtener foto
-	file, header, err := r.FormFile("photo")
-	if err != nil {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, fmt.Errorf("photo is required"))
-		return
+		return nil, apiErrorf(CodeBadRequest, "invalid age")
 	}
-	defer file.Close()
 
-	// 4) Guardar archivo en disco
-	uploadsDir := "uploads/"
-	os.MkdirAll(uploadsDir, os.ModePerm)
-	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), header.Filename)
-	outPath := filepath.Join(uploadsDir, filename)
-	outFile, err := os.Create(outPath)
+	// 2) Validar y guardar la foto en uploads/
+	photoPath, err := s.storePhoto(r)
 	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
-	}
-	defer outFile.Close()
-	if _, err := io.Copy(outFile, file); err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+		return nil, err
 	}
 
-	// 5) Crear persona en BD
+	// 3) Crear persona, su línea de tiempo y su muerte inicial (40s) en una
+	// transacción; la foto se borra si no llega a confirmarse y la muerte se
+	// encola después del commit
+	ctx := r.Context()
 	person := &models.Person{
 		Name:      name,
 		Age:       age,
-		PhotoPath: "/static/" + filename, // asumiendo servir uploads como /static/
-	}
-	person, err = s.PeopleRepository.Save(person)
+		PhotoPath: photoPath,
+		Version:   1,
+	}
+	err = s.UnitOfWork.WithContext(ctx).Do(func(tx *repository.Tx) error {
+		tx.OnRollback(func() { s.removePhoto(ctx, photoPath) })
+		if _, err := tx.People.Save(person); err != nil {
+			return err
+		}
+		if err := recordCreated(tx.Events, person); err != nil {
+			return err
+		}
+		return s.scheduleHeartAttack(ctx, tx, person)
+	})
 	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+		return nil, err
 	}
-
-	// 6) Encolar muerte inicial (40s)
-	kill := &models.Kill{PersonId: person.ID}
-	duration := time.Duration(s.Config.KillDuration) * time.Second
-	s.taskQueue.StartTask(int(person.ID), duration, func(k *models.Kill) error {
-		return s.PeopleRepository.MarkHeartAttack(k.PersonId) // método a implementar
-	}, kill)
-
-	// 7) Responder
-	resp := person.ToPersonResponseDto()
-	data, _ := json.Marshal(resp)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	w.Write(data)
-	s.logger.Info(http.StatusCreated, r.URL.Path, start)
+	return person, nil
 }
 
-func (s *Server) handleEditPerson(w http.ResponseWriter, r *http.Request) {
-	var p api.PersonRequestDto
-	err := json.NewDecoder(r.Body).Decode(&p)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-	vars := mux.Vars(r)
-	id, err := strconv.ParseInt(vars["id"], 10, 32)
+func (s *Server) handleEditPerson(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.editPerson(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-	}
-	person, err := s.PeopleRepository.FindById(int(id))
-	if person == nil && err == nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	}
-	person.Name = p.Nombre
-	person.Age = int(p.Edad)
-	person, err = s.PeopleRepository.Save(person)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return err
 	}
 	pResponse := &api.PersonResponseDto{
 		ID:            int(person.ID),
@@ -209,127 +166,198 @@ func (s *Server) handleEditPerson(w http.ResponseWriter, r *http.Request) {
 	}
 	result, err := json.Marshal(pResponse)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return err
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", personETag(person))
 	w.WriteHeader(http.StatusAccepted)
 	w.Write(result)
+	return nil
 }
 
-func (s *Server) handleDeletePerson(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.ParseInt(vars["id"], 10, 32)
+// editPerson sobrescribe nombre y edad con el JSON recibido si If-Match
+// coincide con la versión actual, con las mismas reglas que PATCH
+func (s *Server) editPerson(r *http.Request) (*models.Person, error) {
+	var p api.PersonRequestDto
+	if err := decodeJSON(r, &p); err != nil {
+		return nil, err
+	}
+	id, err := pathID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		return nil, err
 	}
-	person, err := s.PeopleRepository.FindById(int(id))
-	if person == nil && err == nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
+	person, err := s.findPerson(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIfMatch(r, personETag(person)); err != nil {
+		return nil, err
+	}
+	current := personPatchDoc{Name: person.Name, Age: person.Age, Cause: person.Cause, Details: person.Details}
+	next := personPatchDoc{Name: p.Nombre, Age: int(p.Edad), Cause: person.Cause, Details: person.Details}
+	if _, fieldErrors := personPatchUpdates(person, current, next); len(fieldErrors) > 0 {
+		return nil, &APIError{
+			Code:   CodeUnprocessable,
+			Detail: fmt.Sprintf("%d field(s) cannot be changed", len(fieldErrors)),
+			Errors: fieldErrors,
+		}
+	}
+	err = s.UnitOfWork.WithContext(r.Context()).Do(func(tx *repository.Tx) error {
+		updated, err := tx.People.UpdateIfVersion(person.ID, person.Version, map[string]interface{}{
+			"name": p.Nombre,
+			"age":  int(p.Edad),
+		})
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return staleVersion(id)
+		}
+		return recordEdits(tx.Events, person.ID, current, next)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return s.findPerson(r.Context(), id)
+}
+
+func (s *Server) handleDeletePerson(w http.ResponseWriter, r *http.Request) error {
+	if hardDelete(r) {
+		return s.handlePurgePerson(w, r)
+	}
+	id, err := pathID(r)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return err
 	}
-	err = s.PeopleRepository.Delete(person)
+	person, err := s.findPerson(r.Context(), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return err
+	}
+	if err := checkIfMatch(r, personETag(person)); err != nil {
+		return err
+	}
+	ctx := r.Context()
+	err = s.UnitOfWork.WithContext(ctx).Do(func(tx *repository.Tx) error {
+		deleted, err := tx.People.DeleteIfVersion(person.ID, person.Version)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return staleVersion(id)
+		}
+		// El borrado ya revocó sus kills; sus tareas pendientes no deben
+		// ejecutarse sobre una persona en la papelera
+		if err := s.cancelTask(ctx, tx, id); err != nil {
+			return err
+		}
+		return tx.Events.Record(person.ID, models.EventDeleted, nil)
+	})
+	if err != nil {
+		return err
 	}
 	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) HandleAddCause(w http.ResponseWriter, r *http.Request) error {
+	if _, err := s.addCause(r); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+func (s *Server) HandleAddDetails(w http.ResponseWriter, r *http.Request) error {
+	if _, err := s.addDetails(r); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusAccepted)
+	return nil
 }
 
-func (s *Server) HandleAddCause(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+// addCause guarda la causa y reprograma la muerte; devuelve el id de la persona
+func (s *Server) addCause(r *http.Request) (int, error) {
+	id, err := pathID(r)
 	if err != nil {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, err)
-		return
+		return 0, err
 	}
 
 	// Leer JSON { "cause": "texto corto" }
 	var payload struct {
 		Cause string `json:"cause"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, err)
-		return
+	if err := decodeJSON(r, &payload); err != nil {
+		return 0, err
+	}
+
+	// Actualizar causa en BD y reprogramar la muerte (sustituye la task de
+	// 40s inicial) 6m40s después; se encola tras el commit
+	ctx := r.Context()
+	err = s.UnitOfWork.WithContext(ctx).Do(func(tx *repository.Tx) error {
+		if err := tx.People.AddCause(uint(id), payload.Cause); err != nil {
+			return err
+		}
+		if err := s.scheduleDeath(ctx, tx, id, time.Duration(s.Config.KillDurationWithDescription)*time.Second); err != nil {
+			return err
+		}
+		return tx.Events.Record(uint(id), models.EventCauseAdded, map[string]any{"cause": payload.Cause})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, apiErrorf(CodePersonNotFound, "person with id %d not found", id)
 	}
-
-	// Cancelar task de 40s inicial
-	s.taskQueue.CancelTask(id)
-
-	// Actualizar causa en BD
-	if err := s.PeopleRepository.AddCause(uint(id), payload.Cause); err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+	if err != nil {
+		return 0, err
 	}
-
-	// Encolar la muerte 6m40s después
-	duration := time.Duration(s.Config.KillDurationWithDescription) * time.Second
-	s.taskQueue.StartTask(id, duration, func(_ *models.Kill) error {
-		return s.PeopleRepository.MarkDeath(uint(id))
-	}, nil)
-
-	w.WriteHeader(http.StatusAccepted)
-	s.logger.Info(http.StatusAccepted, r.URL.Path, start)
+	return id, nil
 }
 
-func (s *Server) HandleAddDetails(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+// addDetails guarda los detalles y reprograma la muerte; devuelve el id de la persona
+func (s *Server) addDetails(r *http.Request) (int, error) {
+	id, err := pathID(r)
 	if err != nil {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, err)
-		return
+		return 0, err
 	}
 
 	var payload struct {
 		Details string `json:"details"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, err)
-		return
+	if err := decodeJSON(r, &payload); err != nil {
+		return 0, err
+	}
+
+	// Actualizar detalles en BD y reprogramar la muerte final (sustituye la
+	// task de 6m40s) 40s después; se encola tras el commit
+	ctx := r.Context()
+	err = s.UnitOfWork.WithContext(ctx).Do(func(tx *repository.Tx) error {
+		if err := tx.People.AddDetails(uint(id), payload.Details); err != nil {
+			return err
+		}
+		if err := s.scheduleDeath(ctx, tx, id, time.Duration(s.Config.KillDuration)*time.Second); err != nil {
+			return err
+		}
+		return tx.Events.Record(uint(id), models.EventDetailsAdded, map[string]any{"details": payload.Details})
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, apiErrorf(CodePersonNotFound, "person with id %d not found", id)
 	}
-
-	// Cancelar task de 6m40s
-	s.taskQueue.CancelTask(id)
-
-	// Actualizar detalles en BD
-	if err := s.PeopleRepository.AddDetails(uint(id), payload.Details); err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+	if err != nil {
+		return 0, err
 	}
-
-	// Encolar muerte final 40s después
-	duration := time.Duration(s.Config.KillDuration) * time.Second
-	s.taskQueue.StartTask(id, duration, func(_ *models.Kill) error {
-		return s.PeopleRepository.MarkDeath(uint(id))
-	}, nil)
-
-	w.WriteHeader(http.StatusAccepted)
-	s.logger.Info(http.StatusAccepted, r.URL.Path, start)
+	return id, nil
 }
 
-func (s *Server) HandleGetStatus(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
+func (s *Server) HandleGetStatus(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r)
 	if err != nil {
-		s.HandleError(w, http.StatusBadRequest, r.URL.Path, err)
-		return
-	}
-	person, err := s.PeopleRepository.FindById(id)
-	if person == nil && err == nil {
-		s.HandleError(w, http.StatusNotFound, r.URL.Path,
-			fmt.Errorf("person %d not found", id))
-		return
+		return err
 	}
+	person, err := s.findPerson(r.Context(), id)
 	if err != nil {
-		s.HandleError(w, http.StatusInternalServerError, r.URL.Path, err)
-		return
+		return err
+	}
+
+	if notModified(w, r, personETag(person)) {
+		return nil
 	}
 
 	// Devolver solo el DTO con estado
@@ -337,5 +365,123 @@ func (s *Server) HandleGetStatus(w http.ResponseWriter, r *http.Request) {
 	data, _ := json.Marshal(resp)
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(data)
-	s.logger.Info(http.StatusOK, r.URL.Path, start)
+	return nil
+}
+
+// scheduleHeartAttack programa en tx la muerte inicial por ataque al corazón
+func (s *Server) scheduleHeartAttack(ctx context.Context, tx *repository.Tx, person *models.Person) error {
+	return s.scheduleTask(ctx, tx, TaskHeartAttack, person.ID, time.Duration(s.Config.KillDuration)*time.Second, "")
+}
+
+// scheduleDeath sustituye en tx la tarea pendiente de la persona por la
+// muerte dentro de duration
+func (s *Server) scheduleDeath(ctx context.Context, tx *repository.Tx, id int, duration time.Duration) error {
+	return s.scheduleTask(ctx, tx, TaskDeath, uint(id), duration, "")
+}
+
+// scheduleTask guarda la tarea en tx, junto con la escritura que la
+// programa, y la encola desde esa fila cuando tx se confirma. Si el proceso
+// cae antes, resumeTasks la encola al arrancar
+func (s *Server) scheduleTask(ctx context.Context, tx *repository.Tx, kind TaskKind, id uint, duration time.Duration, description string) error {
+	task := &models.ScheduledTask{PersonID: id, Kind: string(kind), RunAt: time.Now().Add(duration), Description: description}
+	if err := tx.Tasks.Put(task); err != nil {
+		return err
+	}
+	if kind != TaskKill {
+		// La muerte programada forma parte del stream de la persona
+		if err := tx.People.ScheduleDeath(id, string(kind), task.RunAt); err != nil {
+			return err
+		}
+	}
+	tx.AfterCommit(func() { s.armTask(ctx, task) })
+	return nil
+}
+
+// cancelTask borra en tx la tarea pendiente de la persona y la saca de la
+// cola cuando tx se confirma
+func (s *Server) cancelTask(ctx context.Context, tx *repository.Tx, id int) error {
+	if _, err := tx.Tasks.Delete(uint(id), ""); err != nil {
+		return err
+	}
+	tx.AfterCommit(func() { s.taskQueue.CancelTask(ctx, id) })
+	return nil
+}
+
+// resumeTasks encola las tareas guardadas al arrancar: las que seguían
+// pendientes al parar el servidor y las que no llegaron a encolarse tras su
+// commit. Las que ya tocaban se ejecutan enseguida
+func (s *Server) resumeTasks(ctx context.Context) error {
+	tasks, err := s.TaskRepository.WithContext(ctx).FindAll()
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		s.armTask(ctx, task)
+	}
+	if len(tasks) > 0 {
+		s.logger.InfoContext(ctx, "pending tasks resumed", "tasks", len(tasks))
+	}
+	return nil
+}
+
+// armTask encola la tarea guardada en task. Es el único sitio que traduce
+// un TaskKind a su función, para que una tarea restaurada de un backup o
+// recuperada al arrancar haga lo mismo
+func (s *Server) armTask(ctx context.Context, task *models.ScheduledTask) {
+	kind, id := TaskKind(task.Kind), int(task.PersonID)
+	var kill *models.Kill
+	var run func(ctx context.Context, k *models.Kill) (bool, error)
+	switch kind {
+	case TaskHeartAttack:
+		kill = &models.Kill{PersonId: task.PersonID}
+		run = func(ctx context.Context, k *models.Kill) (bool, error) {
+			return s.PeopleRepository.WithContext(ctx).MarkHeartAttack(k.PersonId)
+		}
+	case TaskDeath:
+		run = func(ctx context.Context, _ *models.Kill) (bool, error) {
+			return s.PeopleRepository.WithContext(ctx).MarkDeath(uint(id))
+		}
+	case TaskKill:
+		// Guardar la kill no marca ninguna muerte
+		kill = &models.Kill{PersonId: task.PersonID, Description: task.Description}
+		run = func(ctx context.Context, k *models.Kill) (bool, error) {
+			_, err := s.KillRepository.WithContext(ctx).Save(k)
+			return false, err
+		}
+	default:
+		s.logger.WarnContext(ctx, "unknown task kind, not scheduled", "task_id", id, "kind", kind)
+		return
+	}
+	s.taskQueue.StartTask(ctx, id, kind, time.Until(task.RunAt), s.metrics.deathTask(kind, run), kill)
+}
+
+// findPerson busca la persona y devuelve person_not_found si no existe
+func (s *Server) findPerson(ctx context.Context, id int) (*models.Person, error) {
+	person, err := s.PeopleRepository.WithContext(ctx).FindById(id)
+	if err != nil {
+		return nil, err
+	}
+	if person == nil {
+		return nil, apiErrorf(CodePersonNotFound, "person with id %d not found", id)
+	}
+	return person, nil
+}
+
+// pathID lee el parámetro {id} de la ruta
+func pathID(r *http.Request) (int, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		return 0, &APIError{Code: CodeInvalidID, Detail: fmt.Sprintf("invalid id %q", mux.Vars(r)["id"]), Err: err}
+	}
+	return int(id), nil
+}
+
+// decodeJSON decodifica el cuerpo rechazando campos desconocidos
+func decodeJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return wrapAPIError(CodeMalformedBody, err)
+	}
+	return nil
 }
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxUploadSize limita el cuerpo de las peticiones con foto (10 MB)
+const maxUploadSize = 10 << 20
+
+// photoTypes son los tipos de imagen aceptados, detectados por contenido
+var photoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// photoLimitReader lee una foto de un ZIP sin fiarse del tamaño de la
+// cabecera: pasado maxUploadSize falla en vez de truncar en silencio
+type photoLimitReader struct {
+	r    io.Reader
+	left int64
+}
+
+func newPhotoLimitReader(r io.Reader) *photoLimitReader {
+	return &photoLimitReader{r: r, left: maxUploadSize}
+}
+
+func (l *photoLimitReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return 0, apiErrorf(CodePayloadTooLarge, "exceeds %d bytes", maxUploadSize)
+	}
+	return n, err
+}
+
+// parsePhotoForm aplica el límite de tamaño y parsea el multipart
+func parsePhotoForm(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		return &APIError{Code: CodeMalformedBody, Detail: "error parsing form", Err: err}
+	}
+	return nil
+}
+
+// storePhoto guarda el campo "photo" del formulario ya parseado y devuelve
+// la ruta pública (/static/...)
+func (s *Server) storePhoto(r *http.Request) (string, error) {
+	file, header, err := r.FormFile("photo")
+	if err != nil {
+		return "", apiErrorf(CodeBadRequest, "photo is required")
+	}
+	defer file.Close()
+	return s.savePhoto(file, header.Filename)
+}
+
+// savePhoto valida que el contenido sea una imagen y lo guarda en uploads/
+// con un nombre único. Se escribe a un temporal y se renombra, así /static/
+// nunca sirve una foto a medias. Lo usan el multipart y la importación masiva
+func (s *Server) savePhoto(file io.Reader, originalName string) (string, error) {
+	// El tipo se detecta con los primeros bytes, no con la extensión
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", apiErrorf(CodeBadRequest, "photo is empty")
+	}
+	head = head[:n]
+	if contentType := http.DetectContentType(head); !photoTypes[contentType] {
+		return "", apiErrorf(CodeUnsupportedMedia, "photo must be a JPEG, PNG, GIF or WebP image, got %s", contentType)
+	}
+
+	if err := os.MkdirAll(uploadsDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(uploadsDir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	written, err := io.Copy(tmp, io.MultiReader(bytes.NewReader(head), file))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), filepath.Base(originalName))
+	if err := os.Rename(tmp.Name(), filepath.Join(uploadsDir, filename)); err != nil {
+		return "", err
+	}
+	s.metrics.uploadedBytes.Add(float64(written))
+	return "/static/" + filename, nil
+}
+
+// removePhoto borra el archivo de una ruta pública /static/...; los errores
+// solo se registran porque ninguna persona apunta ya a esa foto
+func (s *Server) removePhoto(ctx context.Context, photoPath string) {
+	name, ok := strings.CutPrefix(photoPath, "/static/")
+	if !ok || name == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(uploadsDir, filepath.Base(name))); err != nil && !os.IsNotExist(err) {
+		s.logger.WarnContext(ctx, "could not remove photo", "path", photoPath, "error", err)
+	}
+}
+
+func (s *Server) HandleReplacePhoto(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.replacePhoto(w, r)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, http.StatusOK, person, person.ToPersonResponseDto())
+	return nil
+}
+
+// replacePhoto guarda la foto nueva, cambia PhotoPath solo si la persona
+// sigue en la versión de If-Match y después borra la anterior
+func (s *Server) replacePhoto(w http.ResponseWriter, r *http.Request) (*models.Person, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsePhotoForm(w, r); err != nil {
+		return nil, err
+	}
+	person, err := s.findPerson(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIfMatch(r, personETag(person)); err != nil {
+		return nil, err
+	}
+
+	newPath, err := s.storePhoto(r)
+	if err != nil {
+		return nil, err
+	}
+	// La foto nueva se borra si la transacción no se confirma; la vieja,
+	// solo después del commit
+	ctx := r.Context()
+	oldPath := person.PhotoPath
+	err = s.UnitOfWork.WithContext(ctx).Do(func(tx *repository.Tx) error {
+		tx.OnRollback(func() { s.removePhoto(ctx, newPath) })
+		swapped, err := tx.People.ReplacePhoto(person.ID, person.Version, newPath)
+		if err != nil {
+			return err
+		}
+		if !swapped {
+			return staleVersion(id)
+		}
+		tx.AfterCommit(func() {
+			s.removePhoto(ctx, oldPath)
+			s.logger.InfoContext(ctx, "photo replaced", "person_id", id, "old_photo", oldPath, "new_photo", newPath)
+		})
+		return tx.Events.Record(person.ID, models.EventPhotoUploaded, map[string]any{"photo_url": newPath, "previous_photo_url": oldPath})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.findPerson(r.Context(), id)
+}
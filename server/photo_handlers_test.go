@@ -0,0 +1,88 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func putPhoto(t *testing.T, h http.Handler, path string, content io.Reader) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, _ := writer.CreateFormFile("photo", "ryuk.jpg")
+	io.Copy(part, content)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, path, &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("If-Match", currentETag(t, h, strings.TrimSuffix(path, "/photo")))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestReplacePhoto(t *testing.T) {
+	s := createTestServer(t)
+	created := createPersonAt(t, s, "/v1/people")
+	id := int(created["person_id"].(float64))
+	defer s.CancelTaskForTest(id)
+	oldPhoto := created["photo_url"].(string)
+
+	file, _ := os.Open("./testdata/light.jpg")
+	defer file.Close()
+	rec := putPhoto(t, s.GetRouter(), "/v1/people/"+strconv.Itoa(id)+"/photo", file)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var person map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &person)
+	newPhoto := person["photo_url"].(string)
+	if newPhoto == oldPhoto || !strings.HasPrefix(newPhoto, "/static/") {
+		t.Fatalf("photo_url no cambió: %q -> %q", oldPhoto, newPhoto)
+	}
+	defer os.Remove(filepath.Join("uploads", strings.TrimPrefix(newPhoto, "/static/")))
+
+	if _, err := os.Stat(filepath.Join("uploads", strings.TrimPrefix(oldPhoto, "/static/"))); !os.IsNotExist(err) {
+		t.Errorf("la foto anterior debería haberse borrado: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join("uploads", strings.TrimPrefix(newPhoto, "/static/"))); err != nil {
+		t.Errorf("la foto nueva no está en uploads/: %v", err)
+	}
+
+	// El cambio queda en el historial con la foto anterior
+	events := getTimeline(t, s.GetRouter(), "/v1/people/"+strconv.Itoa(id)+"/timeline")
+	if events[len(events)-1] != "photo_uploaded/anonymous" {
+		t.Errorf("timeline = %v, esperaba photo_uploaded al final", events)
+	}
+	timeline := serve(s.GetRouter(), http.MethodGet, "/v1/people/"+strconv.Itoa(id)+"/timeline", "")
+	if !strings.Contains(timeline.Body.String(), `"previous_photo_url":"`+oldPhoto+`"`) {
+		t.Errorf("falta previous_photo_url en %s", timeline.Body.String())
+	}
+}
+
+func TestReplacePhotoRejectsNonImage(t *testing.T) {
+	s := createTestServer(t)
+	created := createPersonAt(t, s, "/v2/people")
+	id := int(created["id"].(float64))
+	defer s.CancelTaskForTest(id)
+
+	rec := putPhoto(t, s.GetRouter(), "/v2/people/"+strconv.Itoa(id)+"/photo", strings.NewReader("esto no es una imagen"))
+	decodeProblem(t, rec, http.StatusUnsupportedMediaType)
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/people/"+strconv.Itoa(id), nil)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	var person map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &person)
+	if person["photo_url"] != created["photo_url"] {
+		t.Errorf("la foto no debería cambiar: %v", person["photo_url"])
+	}
+}
@@ -1,44 +1,109 @@
 package server
 
 import (
+	"backend-avanzada/logger"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-// GetRouter expone el router con CORS, logging y todas las rutas
+// GetRouter expone el router con request id, access log, métricas, CORS y
+// todas las rutas. El request id, el access log y las métricas envuelven al
+// router entero: así cubren también las peticiones que no casan con ninguna
+// ruta
 func (s *Server) GetRouter() http.Handler {
+	return logger.RequestID(s.accessLog(s.metrics.Middleware(s.Routes())))
+}
+
+// Routes es el router de mux con los middlewares por ruta y todas las rutas
+func (s *Server) Routes() *mux.Router {
 	router := mux.NewRouter()
+	router.NotFoundHandler = s.notFoundHandler()
+	router.MethodNotAllowedHandler = s.methodNotAllowedHandler()
 
+	// Middleware de actor para el registro de auditoría (X-Actor)
+	router.Use(s.auditActor)
+	// Plantilla de la ruta para el access log y las métricas
+	router.Use(recordRoute)
+	// Middleware de trazas (un span por ruta)
+	router.Use(s.tracing)
 	// Middleware CORS
 	router.Use(middlewareCORS)
-	// Middleware logging
-	router.Use(s.logger.RequestLogger)
+	// Middleware de validación contra api/openapi.json
+	router.Use(s.validateRequests)
 
 	// Servir archivos estáticos desde uploads/ en /static/
 	router.PathPrefix("/static/").
-		Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("uploads/"))))
+		Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(uploadsDir))))
+
+	// API versionada. /v1 es la API original y /v2 usa los DTOs nuevos; las
+	// rutas sin versión siguen sirviendo /v1 con cabeceras de obsolescencia
+	s.registerV1Routes(router.PathPrefix("/v1").Subrouter())
+	s.registerV2Routes(router.PathPrefix("/v2").Subrouter())
+	legacy := router.NewRoute().Subrouter()
+	legacy.Use(s.deprecated)
+	s.registerV1Routes(legacy)
+
+	// Exportación de datos (csv, jsonl o xlsx)
+	router.HandleFunc("/export/people", s.handle(s.HandleExportPeople)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/export/kills", s.handle(s.HandleExportKills)).
+		Methods(http.MethodGet, http.MethodOptions)
 
-	// Rutas de personas
-	router.HandleFunc("/people", s.HandlePeople).
+	// Registro de auditoría de todas las escrituras, protegido con
+	// admin_token como /admin
+	router.HandleFunc("/audit", s.handle(s.requireAdmin(s.HandleAudit))).
+		Methods(http.MethodGet, http.MethodOptions)
+
+	// Notificaciones del outbox y su estado de entrega
+	router.HandleFunc("/outbox", s.handle(s.HandleOutbox)).
+		Methods(http.MethodGet, http.MethodOptions)
+
+	// Suscripciones a las notificaciones, con su registro de entregas;
+	// protegidas con admin_token como /admin
+	router.HandleFunc("/webhooks", s.handle(s.requireAdmin(s.HandleWebhooks))).
 		Methods(http.MethodGet, http.MethodPost, http.MethodOptions)
-	router.HandleFunc("/people/{id}", s.HandlePeopleWithId).
-		Methods(http.MethodGet, http.MethodPut, http.MethodDelete, http.MethodOptions)
-	router.HandleFunc("/people/{id}/cause", s.HandleAddCause).
+	router.HandleFunc("/webhooks/{id}", s.handle(s.requireAdmin(s.HandleWebhookWithId))).
+		Methods(http.MethodGet, http.MethodDelete, http.MethodOptions)
+	router.HandleFunc("/webhooks/{id}/deliveries", s.handle(s.requireAdmin(s.HandleWebhookDeliveries))).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/webhooks/{id}/test", s.handle(s.requireAdmin(s.HandleWebhookTest))).
 		Methods(http.MethodPost, http.MethodOptions)
-	router.HandleFunc("/people/{id}/details", s.HandleAddDetails).
+
+	// Backup y restauración completos, protegidos con admin_token
+	router.HandleFunc("/admin/backup", s.handle(s.requireAdmin(s.HandleBackup))).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/admin/restore", s.handle(s.requireAdmin(s.HandleRestore))).
 		Methods(http.MethodPost, http.MethodOptions)
-	router.HandleFunc("/people/{id}/status", s.HandleGetStatus).
+
+	// Rutas de salud para docker-compose y orquestadores
+	router.HandleFunc("/healthz", s.HandleHealthz).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/readyz", s.HandleReadyz).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/status", s.HandleStatus).
 		Methods(http.MethodGet, http.MethodOptions)
 
-	// Rutas de kills
-	router.HandleFunc("/kills", s.HandleKills).
+	// Métricas en formato Prometheus
+	router.Handle("/metrics", s.metrics.Handler()).
 		Methods(http.MethodGet, http.MethodOptions)
-	router.HandleFunc("/kills/{id}", s.HandleKillsWithId).
-		Methods(http.MethodPost, http.MethodDelete, http.MethodOptions)
 
-	// Ruta de configuración
-	router.HandleFunc("/config", s.HandleGetConfig).
+	// Catálogo de códigos de error (tipos de problem+json)
+	router.HandleFunc("/problems", s.HandleProblemTypes).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/problems/{code}", s.handle(s.HandleProblemType)).
+		Methods(http.MethodGet, http.MethodOptions)
+
+	// Documentación OpenAPI
+	router.HandleFunc("/openapi.json", s.HandleOpenAPI).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/docs", s.HandleDocs).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/docs/redoc.standalone.js", s.HandleRedoc).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/docs/docs.js", s.HandleDocsScript).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/docs/docs.css", s.HandleDocsStyle).
 		Methods(http.MethodGet, http.MethodOptions)
 
 	return router
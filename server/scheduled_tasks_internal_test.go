@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend-avanzada/config"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+)
+
+func TestScheduledTasksSurviveRestart(t *testing.T) {
+	s := NewTestServer(&config.Config{
+		Database:                    "postgres",
+		KillDuration:                2,
+		KillDurationWithDescription: 4,
+	})
+	s.ResetDBForTest()
+	ctx := context.Background()
+	person, err := s.PeopleRepository.Save(&models.Person{Name: "Misa", Age: 20, Version: 1})
+	if err != nil {
+		t.Fatalf("persona: %v", err)
+	}
+	id := int(person.ID)
+
+	// Una transacción que no se confirma no deja ni fila ni tarea
+	rollback := errors.New("rollback")
+	err = s.UnitOfWork.Do(func(tx *repository.Tx) error {
+		if err := s.scheduleHeartAttack(ctx, tx, person); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("Do() = %v", err)
+	}
+	if tasks, _ := s.TaskRepository.FindAll(); len(tasks) != 0 || s.taskQueue.HasTask(id) {
+		t.Fatalf("tras el rollback: %d filas, en cola %v", len(tasks), s.taskQueue.HasTask(id))
+	}
+
+	// Con commit se guarda la fila y se encola desde ella
+	err = s.UnitOfWork.Do(func(tx *repository.Tx) error {
+		return s.scheduleHeartAttack(ctx, tx, person)
+	})
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	tasks, _ := s.TaskRepository.FindAll()
+	if len(tasks) != 1 || tasks[0].Kind != string(TaskHeartAttack) || !s.taskQueue.HasTask(id) {
+		t.Fatalf("tras el commit: %+v, en cola %v", tasks, s.taskQueue.HasTask(id))
+	}
+
+	// Al parar se pierde la cola pero no la fila; al arrancar se reanuda, y
+	// la que ya tocaba se ejecuta enseguida
+	s.taskQueue.Stop()
+	s.taskQueue = NewTaskQueue(s.logger)
+	s.taskQueue.store = s.TaskRepository
+	tasks[0].RunAt = time.Now().Add(-time.Second)
+	if err := s.TaskRepository.Put(tasks[0]); err != nil {
+		t.Fatalf("Put() = %v", err)
+	}
+	if err := s.resumeTasks(ctx); err != nil {
+		t.Fatalf("resumeTasks() = %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		dead, _ := s.PeopleRepository.FindById(id)
+		remaining, _ := s.TaskRepository.FindAll()
+		if dead != nil && dead.DeathTime != nil && len(remaining) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("la tarea reanudada no se ejecutó: %+v, %d filas", dead, len(remaining))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -5,30 +5,56 @@ import (
 	"backend-avanzada/config"
 	"backend-avanzada/logger"
 	"backend-avanzada/models"
+	"backend-avanzada/outbox"
 	"backend-avanzada/repository"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// uploadsDir es donde se guardan las fotos servidas en /static/
+const uploadsDir = "uploads/"
+
 type Server struct {
-	DB               *gorm.DB
-	Config           *config.Config
-	PeopleRepository *repository.PeopleRepository
-	KillRepository   *repository.KillRepository
-	logger           *logger.Logger
-	taskQueue        *TaskQueue
+	DB                    *gorm.DB
+	Config                *config.Config
+	PeopleRepository      *repository.PeopleRepository
+	KillRepository        *repository.KillRepository
+	IdempotencyRepository *repository.IdempotencyRepository
+	AuditRepository       *repository.AuditRepository
+	EventRepository       *repository.EventRepository
+	EventStore            *repository.EventStore
+	UnitOfWork            *repository.UnitOfWork
+	OutboxRepository      *repository.OutboxRepository
+	WebhookRepository     *repository.WebhookRepository
+	TaskRepository        *repository.TaskRepository
+	Outbox                *outbox.Relay
+	webhooks              *outbox.SubscriptionSink
+	logger                *logger.Logger
+	taskQueue             *TaskQueue
+	metrics               *Metrics
+	validator             *requestValidator
+	tracer                trace.Tracer
+	spanRecorder          *spanRecorder
+	shutdownTracing       func(context.Context) error
+	startedAt             time.Time
 }
 
 func NewServer() *Server {
 	s := &Server{
 		logger:    logger.NewLogger(),
-		taskQueue: NewTaskQueue(),
+		startedAt: time.Now(),
 	}
 	var config config.Config
 	configFile, err := os.ReadFile("config/config.json")
@@ -38,36 +64,128 @@ func NewServer() *Server {
 	if err := json.Unmarshal(configFile, &config); err != nil {
 		s.logger.Fatal(err)
 	}
+	// El token de administración puede llegar por entorno para no guardarlo
+	// en config.json
+	if token := os.Getenv("DEATHNOTE_ADMIN_TOKEN"); token != "" {
+		config.AdminToken = token
+	}
 	s.Config = &config
+	s.logger = logger.New(config.LogFormat, os.Stdout)
+	s.taskQueue = NewTaskQueue(s.logger)
+	s.metrics = NewMetrics(s.taskQueue)
+	if err := s.initTracing(); err != nil {
+		s.logger.Fatal(err)
+	}
+	validator, err := newRequestValidator()
+	if err != nil {
+		s.logger.Fatal(err)
+	}
+	s.validator = validator
 	return s
 }
 
 func NewTestServer(cfg *config.Config) *Server {
 	s := &Server{
 		Config:    cfg,
-		logger:    logger.NewLogger(),
-		taskQueue: NewTaskQueue(),
+		logger:    logger.New(cfg.LogFormat, os.Stdout),
+		startedAt: time.Now(),
+	}
+	s.taskQueue = NewTaskQueue(s.logger)
+	s.metrics = NewMetrics(s.taskQueue)
+	if err := s.initTracing(); err != nil {
+		s.logger.Fatal(err)
 	}
+	validator, err := newRequestValidator()
+	if err != nil {
+		s.logger.Fatal(err)
+	}
+	s.validator = validator
 	s.initDB()
-	s.PeopleRepository = repository.NewPeopleRepository(s.DB)
-	s.KillRepository = repository.NewKillRepository(s.DB)
 	return s
 }
 
 func (s *Server) StartServer() {
-	fmt.Println("Inicializando base de datos...")
+	s.logger.Info("Inicializando base de datos...", "database", s.Config.Database)
 	s.initDB()
-	fmt.Println("Inicializando mux...")
+	if err := s.resumeTasks(context.Background()); err != nil {
+		s.logger.Fatal(err)
+	}
+	s.logger.Info("Inicializando mux...")
 	srv := &http.Server{
 		Addr:    s.Config.Address,
 		Handler: s.GetRouter(),
 	}
-	fmt.Println("Escuchando en el puerto ", s.Config.Address)
-	if err := srv.ListenAndServe(); err != nil {
+
+	// Al recibir SIGINT/SIGTERM se detiene la cola (readyz pasa a 503) y se
+	// cierran las conexiones en curso. Las tareas pendientes siguen en
+	// scheduled_tasks y se reanudan al arrancar
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go s.runRetention(ctx)
+	go s.Outbox.Run(ctx, s.outboxInterval())
+	go func() {
+		<-ctx.Done()
+		s.logger.Info("Deteniendo servidor...")
+		s.taskQueue.Stop()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+		if s.shutdownTracing != nil {
+			s.shutdownTracing(shutdownCtx)
+		}
+	}()
+
+	s.logger.Info("Escuchando en el puerto", "address", s.Config.Address)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		s.logger.Fatal(err)
 	}
 }
 
+// retentionInterval es cada cuánto se purgan la papelera y las claves de
+// idempotencia caducadas
+const retentionInterval = time.Hour
+
+// runRetention purga al arrancar y luego cada retentionInterval, hasta que
+// se cancele ctx: la papelera según trash_retention_days y las claves de
+// idempotencia caducadas
+func (s *Server) runRetention(ctx context.Context) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		purged, err := s.PurgeExpiredTrash(ctx)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "trash purge failed", "error", err)
+		} else if purged > 0 {
+			s.logger.InfoContext(ctx, "trash purged", "people", purged)
+		}
+		if err := s.IdempotencyRepository.WithContext(ctx).DeleteExpired(time.Now()); err != nil {
+			s.logger.ErrorContext(ctx, "idempotency key cleanup failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// migratedModels son las tablas que crea AutoMigrate; ResetDBForTest las
+// vacía en orden inverso
+var migratedModels = []any{
+	&models.Person{},
+	&models.Kill{},
+	&models.IdempotencyKey{},
+	&models.AuditEntry{},
+	&models.PersonEvent{},
+	&models.DomainEvent{},
+	&models.PersonVersion{},
+	&models.OutboxMessage{},
+	&models.OutboxDelivery{},
+	&models.Webhook{},
+	&models.WebhookDelivery{},
+	&models.ScheduledTask{},
+}
+
 func (s *Server) initDB() {
 	switch s.Config.Database {
 	case "sqlite":
@@ -89,10 +207,38 @@ func (s *Server) initDB() {
 		}
 		s.DB = db
 	}
-	fmt.Println("Aplicando migraciones...")
-	s.DB.AutoMigrate(&models.Person{}, &models.Kill{})
+	if err := s.DB.Use(&gormTracing{s: s}); err != nil {
+		s.logger.Fatal(err)
+	}
+	s.logger.Info("Aplicando migraciones...")
+	s.DB.AutoMigrate(migratedModels...)
 	s.KillRepository = repository.NewKillRepository(s.DB)
 	s.PeopleRepository = repository.NewPeopleRepository(s.DB)
+	s.IdempotencyRepository = repository.NewIdempotencyRepository(s.DB)
+	s.AuditRepository = repository.NewAuditRepository(s.DB)
+	s.EventRepository = repository.NewEventRepository(s.DB)
+	s.EventStore = repository.NewEventStore(s.DB)
+	s.UnitOfWork = repository.NewUnitOfWork(s.DB)
+	s.OutboxRepository = repository.NewOutboxRepository(s.DB)
+	s.WebhookRepository = repository.NewWebhookRepository(s.DB)
+	s.TaskRepository = repository.NewTaskRepository(s.DB)
+	s.webhooks = outbox.NewSubscriptionSink(s.WebhookRepository, s.Config.WebhookAllowPrivate)
+	s.Outbox = outbox.NewRelay(s.OutboxRepository, s.logger, s.outboxSinks()...)
+	// Las personas creadas antes del event store necesitan un stream para
+	// poder reconstruirse
+	if opened, err := s.EventStore.Backfill(); err != nil {
+		s.logger.Error("could not backfill event streams", "error", err)
+	} else if opened > 0 {
+		s.logger.Info("event streams backfilled", "people", opened)
+	}
+	if opened, err := s.PeopleRepository.BackfillHistory(); err != nil {
+		s.logger.Error("could not backfill person history", "error", err)
+	} else if opened > 0 {
+		s.logger.Info("person history backfilled", "people", opened)
+	}
+	s.taskQueue.audit = s.AuditRepository
+	s.taskQueue.events = s.EventRepository
+	s.taskQueue.store = s.TaskRepository
 }
 
 // HandleGetConfig expone las duraciones configuradas al frontend
@@ -105,7 +251,18 @@ func (s *Server) HandleGetConfig(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(cfg)
 }
 
+// ResetDBForTest vacía todas las tablas migradas para que cada prueba
+// empiece con la base de datos limpia
+func (s *Server) ResetDBForTest() {
+	for i := len(migratedModels) - 1; i >= 0; i-- {
+		db := s.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped()
+		if err := db.Delete(migratedModels[i]).Error; err != nil {
+			s.logger.Error("could not reset table", "error", err)
+		}
+	}
+}
+
 // CancelTaskForTest permite cancelar tareas desde pruebas
 func (s *Server) CancelTaskForTest(id int) {
-	s.taskQueue.CancelTask(id)
+	s.taskQueue.CancelTask(context.Background(), id)
 }
@@ -1,60 +1,262 @@
 package server
 
 import (
+	"backend-avanzada/audit"
+	"backend-avanzada/logger"
 	"backend-avanzada/models"
+	"backend-avanzada/repository"
 	"context"
-	"fmt"
+	"sort"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TaskKind indica qué hace una tarea, para poder volver a programarla tras
+// restaurar un backup
+type TaskKind string
+
+const (
+	TaskHeartAttack TaskKind = "heart_attack" // muerte inicial por ataque al corazón
+	TaskDeath       TaskKind = "death"        // muerte tras causa o detalles
+	TaskKill        TaskKind = "kill"         // guardar una kill manual
 )
 
+// scheduledTask guarda la cancelación de una tarea; se compara por puntero para
+// que una tarea ya terminada no borre del mapa a la que la reemplazó
+type scheduledTask struct {
+	cancel context.CancelFunc
+	kind   TaskKind
+	runAt  time.Time
+	kill   *models.Kill
+}
+
+// PendingTask describe una tarea que aún no se ha ejecutado
+type PendingTask struct {
+	ID    int
+	Kind  TaskKind
+	RunAt time.Time
+	Kill  *models.Kill
+}
+
 type TaskQueue struct {
-	mu    sync.Mutex
-	tasks map[int]context.CancelFunc
+	mu      sync.Mutex
+	tasks   map[int]*scheduledTask
+	stopped bool
+	logger  *logger.Logger
+	tracer  trace.Tracer
+	// audit registra cada tarea programada, cancelada o ejecutada; nil en
+	// las pruebas de la cola sin BD
+	audit *repository.AuditRepository
+	// events escribe la línea de tiempo de la persona de cada tarea
+	events *repository.EventRepository
+	// store borra la fila de scheduled_tasks de cada tarea ejecutada
+	store *repository.TaskRepository
 }
 
-func NewTaskQueue() *TaskQueue {
+func NewTaskQueue(l *logger.Logger) *TaskQueue {
 	return &TaskQueue{
-		tasks: make(map[int]context.CancelFunc),
+		tasks:  make(map[int]*scheduledTask),
+		logger: l,
+		tracer: noop.NewTracerProvider().Tracer(tracerName),
 	}
 }
 
-func (tq *TaskQueue) StartTask(id int, duration time.Duration, task func(k *models.Kill) error, k *models.Kill) {
-	ctx, cancel := context.WithCancel(context.Background())
+// StartTask ejecuta task tras duration. La tarea conserva los valores de ctx
+// (p. ej. el request id) pero no se cancela cuando termina la petición; su span
+// es una traza nueva enlazada al span de la petición que la encoló. Si ya
+// había una tarea con el mismo id se cancela y queda reprogramada
+func (tq *TaskQueue) StartTask(ctx context.Context, id int, kind TaskKind, duration time.Duration, task func(ctx context.Context, k *models.Kill) error, k *models.Kill) {
+	link := trace.LinkFromContext(ctx)
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	t := &scheduledTask{cancel: cancel, kind: kind, runAt: time.Now().Add(duration), kill: k}
 
 	tq.mu.Lock()
-	tq.tasks[id] = cancel
+	if tq.stopped {
+		tq.mu.Unlock()
+		cancel()
+		tq.logger.WarnContext(ctx, "task queue stopped, task not scheduled", "task_id", id)
+		return
+	}
+	previous := tq.tasks[id]
+	tq.tasks[id] = t
 	tq.mu.Unlock()
+	if previous != nil {
+		previous.cancel()
+		tq.record(ctx, models.AuditTaskCancelled, id, previous.auditFields(), nil)
+		tq.event(ctx, id, models.EventTaskRescheduled, map[string]any{
+			"kind": kind, "run_at": t.runAt, "previous_kind": previous.kind, "previous_run_at": previous.runAt,
+		})
+	} else {
+		tq.event(ctx, id, models.EventTaskScheduled, map[string]any{"kind": kind, "run_at": t.runAt})
+	}
+	tq.record(ctx, models.AuditTaskScheduled, id, nil, t.auditFields())
 
 	go func() {
 		defer func() {
 			tq.mu.Lock()
-			delete(tq.tasks, id)
+			if tq.tasks[id] == t {
+				delete(tq.tasks, id)
+			}
 			tq.mu.Unlock()
 		}()
 
 		select {
 		case <-ctx.Done():
-			fmt.Printf("La tarea con ID %d fue cancelada.\n", id)
+			tq.logger.InfoContext(ctx, "task cancelled", "task_id", id)
 		case <-time.After(duration):
-			fmt.Printf("Iniciando tarea asíncrona con id %d...\n", id)
-			err := task(k)
+			// Lo que escriba la tarea se atribuye al sistema, no a quien la programó
+			ctx = audit.WithActor(ctx, audit.ActorSystem)
+			ctx, span := tq.tracer.Start(ctx, "task.execute",
+				trace.WithNewRoot(),
+				trace.WithLinks(link),
+				trace.WithAttributes(
+					attribute.Int("task.id", id),
+					attribute.String("task.kind", string(kind)),
+					attribute.String("task.delay", duration.String()),
+				),
+			)
+			defer span.End()
+
+			tq.logger.InfoContext(ctx, "task started", "task_id", id)
+			err := task(ctx, k)
+			result := repository.AuditFields{"result": "ok"}
 			if err != nil {
-				fmt.Printf("Error en tarea asíncrona: %v\n", err)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				tq.logger.ErrorContext(ctx, "task failed", "task_id", id, "error", err)
+				result = repository.AuditFields{"result": "failed", "error": err.Error()}
+				tq.event(ctx, id, models.EventTaskFailed, map[string]any{"kind": kind, "error": err.Error()})
+			} else if kind == TaskKill {
+				tq.event(ctx, id, models.EventKillRecorded, map[string]any{"description": k.Description})
+			} else {
+				tq.event(ctx, id, models.EventDied, map[string]any{"kind": kind})
 			}
-			fmt.Printf("La tarea con ID %d fue completada tras %v.\n", id, duration)
+			tq.record(ctx, models.AuditTaskFired, id, t.auditFields(), result)
+			tq.complete(ctx, id)
+			tq.logger.InfoContext(ctx, "task completed", "task_id", id, "delay", duration)
 		}
 	}()
 }
 
-func (tq *TaskQueue) CancelTask(id int) bool {
+func (tq *TaskQueue) CancelTask(ctx context.Context, id int) bool {
+	return tq.cancel(ctx, id, "")
+}
+
+// CancelTaskOfKind cancela la tarea del id solo si es de kind; la de otro
+// tipo sigue en cola
+func (tq *TaskQueue) CancelTaskOfKind(ctx context.Context, id int, kind TaskKind) bool {
+	return tq.cancel(ctx, id, kind)
+}
+
+// cancel cancela la tarea del id si es de kind, o de cualquier tipo si kind
+// está vacío
+func (tq *TaskQueue) cancel(ctx context.Context, id int, kind TaskKind) bool {
 	tq.mu.Lock()
-	cancel, exists := tq.tasks[id]
+	t, exists := tq.tasks[id]
+	if exists && kind != "" && t.kind != kind {
+		exists = false
+	}
+	if exists {
+		delete(tq.tasks, id)
+	}
 	tq.mu.Unlock()
 
 	if exists {
-		cancel()
+		t.cancel()
+		tq.record(ctx, models.AuditTaskCancelled, id, t.auditFields(), nil)
+		tq.event(ctx, id, models.EventTaskCancelled, map[string]any{"kind": t.kind})
 		return true
 	}
 	return false
 }
+
+// record añade la entrada de auditoría de la tarea; un fallo solo se
+// registra en el log para no afectar a la tarea
+func (tq *TaskQueue) record(ctx context.Context, action string, id int, before, after repository.AuditFields) {
+	if tq.audit == nil {
+		return
+	}
+	if err := tq.audit.WithContext(ctx).Record(action, models.AuditEntityTask, uint(id), before, after); err != nil {
+		tq.logger.WarnContext(ctx, "could not audit task", "task_id", id, "action", action, "error", err)
+	}
+}
+
+// complete borra la fila de la tarea ejecutada, haya fallado o no. Si el
+// proceso cae antes, la tarea se vuelve a ejecutar al arrancar
+func (tq *TaskQueue) complete(ctx context.Context, id int) {
+	if tq.store == nil {
+		return
+	}
+	if err := tq.store.WithContext(ctx).Complete(uint(id), time.Now()); err != nil {
+		tq.logger.WarnContext(ctx, "could not complete task", "task_id", id, "error", err)
+	}
+}
+
+// event añade el evento a la línea de tiempo de la persona de la tarea
+func (tq *TaskQueue) event(ctx context.Context, id int, eventType string, data map[string]any) {
+	if tq.events == nil {
+		return
+	}
+	if err := tq.events.WithContext(ctx).Record(uint(id), eventType, data); err != nil {
+		tq.logger.WarnContext(ctx, "could not record task event", "task_id", id, "event", eventType, "error", err)
+	}
+}
+
+func (t *scheduledTask) auditFields() repository.AuditFields {
+	return repository.AuditFields{
+		"kind":   string(t.kind),
+		"run_at": t.runAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// HasTask indica si hay una tarea pendiente para el id
+func (tq *TaskQueue) HasTask(id int) bool {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+	_, exists := tq.tasks[id]
+	return exists
+}
+
+// Pending devuelve cuántas tareas siguen esperando a ejecutarse
+func (tq *TaskQueue) Pending() int {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+	return len(tq.tasks)
+}
+
+// Snapshot devuelve las tareas pendientes ordenadas por id
+func (tq *TaskQueue) Snapshot() []PendingTask {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+	pending := make([]PendingTask, 0, len(tq.tasks))
+	for id, t := range tq.tasks {
+		pending = append(pending, PendingTask{ID: id, Kind: t.kind, RunAt: t.runAt, Kill: t.kill})
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	return pending
+}
+
+// Running indica si la cola sigue aceptando tareas
+func (tq *TaskQueue) Running() bool {
+	tq.mu.Lock()
+	defer tq.mu.Unlock()
+	return !tq.stopped
+}
+
+// Stop cancela las tareas pendientes y deja de aceptar nuevas
+func (tq *TaskQueue) Stop() {
+	tq.mu.Lock()
+	tq.stopped = true
+	tasks := tq.tasks
+	tq.tasks = make(map[int]*scheduledTask)
+	tq.mu.Unlock()
+
+	for _, t := range tasks {
+		t.cancel()
+	}
+}
@@ -1,18 +1,20 @@
 package server
 
 import (
+	"context"
 	"testing"
 	"time"
 
+	"backend-avanzada/logger"
 	"backend-avanzada/models"
 )
 
 func TestTaskQueueExecute(t *testing.T) {
-	tq := NewTaskQueue()
+	tq := NewTaskQueue(logger.NewLogger())
 	executed := false
 
 	// Encolamos una tarea muy corta (10ms)
-	tq.StartTask(1, 10*time.Millisecond, func(k *models.Kill) error {
+	tq.StartTask(context.Background(), 1, TaskKill, 10*time.Millisecond, func(ctx context.Context, k *models.Kill) error {
 		executed = true
 		return nil
 	}, &models.Kill{PersonId: 1})
@@ -25,17 +27,17 @@ func TestTaskQueueExecute(t *testing.T) {
 }
 
 func TestTaskQueueCancel(t *testing.T) {
-	tq := NewTaskQueue()
+	tq := NewTaskQueue(logger.NewLogger())
 	executed := false
 
 	// Encolamos una tarea larga (100ms)
-	tq.StartTask(2, 100*time.Millisecond, func(k *models.Kill) error {
+	tq.StartTask(context.Background(), 2, TaskKill, 100*time.Millisecond, func(ctx context.Context, k *models.Kill) error {
 		executed = true
 		return nil
 	}, &models.Kill{PersonId: 2})
 
 	// Cancelamos inmediatamente
-	if !tq.CancelTask(2) {
+	if !tq.CancelTask(context.Background(), 2) {
 		t.Error("CancelTask devolvió false, esperaba true")
 	}
 
@@ -45,3 +47,30 @@ func TestTaskQueueCancel(t *testing.T) {
 		t.Error("La tarea se ejecutó pese a haber sido cancelada")
 	}
 }
+
+func TestTaskQueuePendingAndStop(t *testing.T) {
+	tq := NewTaskQueue(logger.NewLogger())
+	executed := false
+
+	tq.StartTask(context.Background(), 3, TaskKill, 100*time.Millisecond, func(ctx context.Context, k *models.Kill) error {
+		executed = true
+		return nil
+	}, &models.Kill{PersonId: 3})
+
+	if got := tq.Pending(); got != 1 {
+		t.Errorf("Pending() = %d, esperaba 1", got)
+	}
+
+	tq.Stop()
+	if tq.Running() {
+		t.Error("Running() devolvió true tras Stop()")
+	}
+	if got := tq.Pending(); got != 0 {
+		t.Errorf("Pending() = %d tras Stop(), esperaba 0", got)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if executed {
+		t.Error("La tarea se ejecutó pese a detener la cola")
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"net/http"
+)
+
+// HandleTimeline devuelve los eventos de la persona en orden: nombre
+// escrito, foto, causa, detalles, tareas, muerte y borrado. Sigue
+// disponible después de borrar a la persona
+func (s *Server) HandleTimeline(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r)
+	if err != nil {
+		return err
+	}
+	events, err := s.EventRepository.WithContext(r.Context()).ListByPerson(uint(id))
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		person, err := s.PeopleRepository.WithContext(r.Context()).Unscoped().FindById(id)
+		if err != nil {
+			return err
+		}
+		if person == nil {
+			return apiErrorf(CodePersonNotFound, "person with id %d not found", id)
+		}
+	}
+
+	timeline := &api.TimelineDto{PersonID: uint(id), Events: make([]*api.TimelineEventDto, 0, len(events))}
+	for _, e := range events {
+		timeline.Events = append(timeline.Events, e.ToTimelineEventDto())
+	}
+	writeJSON(w, http.StatusOK, timeline)
+	return nil
+}
+
+// recordCreated registra el alta de una persona, el nombre escrito y su
+// foto, con events, el de la unidad de trabajo que la crea
+func recordCreated(events *repository.EventRepository, person *models.Person) error {
+	if err := events.Record(person.ID, models.EventNameWritten, map[string]any{"name": person.Name, "age": person.Age}); err != nil {
+		return err
+	}
+	return events.Record(person.ID, models.EventPhotoUploaded, map[string]any{"photo_url": person.PhotoPath})
+}
+
+// recordEdits registra con events un evento por cada campo que cambió al
+// editar con PUT o PATCH
+func recordEdits(events *repository.EventRepository, id uint, before, after personPatchDoc) error {
+	var err error
+	record := func(eventType string, data map[string]any) {
+		if err == nil {
+			err = events.Record(id, eventType, data)
+		}
+	}
+	if before.Name != after.Name {
+		record(models.EventNameWritten, map[string]any{"name": after.Name, "previous_name": before.Name})
+	}
+	if before.Age != after.Age {
+		record(models.EventAgeChanged, map[string]any{"age": after.Age, "previous_age": before.Age})
+	}
+	if !equalStrings(before.Cause, after.Cause) && after.Cause != nil {
+		record(models.EventCauseAdded, map[string]any{"cause": *after.Cause})
+	}
+	if !equalStrings(before.Details, after.Details) && after.Details != nil {
+		record(models.EventDetailsAdded, map[string]any{"details": *after.Details})
+	}
+	return err
+}
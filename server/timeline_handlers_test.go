@@ -0,0 +1,82 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getTimeline(t *testing.T, h http.Handler, path string) []string {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", path, rec.Code, rec.Body.String())
+	}
+	var timeline struct {
+		Events []struct {
+			Type  string `json:"type"`
+			Actor string `json:"actor"`
+		} `json:"events"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &timeline)
+	var types []string
+	for _, e := range timeline.Events {
+		types = append(types, e.Type+"/"+e.Actor)
+	}
+	return types
+}
+
+func TestPersonTimeline(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+	created := createPersonAt(t, s, "/v1/people")
+	id := int(created["person_id"].(float64))
+	path := fmt.Sprintf("/v1/people/%d/timeline", id)
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/v1/people/%d/details", id), strings.NewReader(`{"details":"cae por las escaleras"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Actor", "kira")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("details: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// La muerte llega cuando vence la tarea (kill_duration = 2 s)
+	deadline := time.Now().Add(5 * time.Second)
+	var events []string
+	for time.Now().Before(deadline) {
+		events = getTimeline(t, router, path)
+		if len(events) > 0 && events[len(events)-1] == "died/system" {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	want := "name_written/anonymous,photo_uploaded/anonymous,task_scheduled/anonymous,details_added/kira,task_rescheduled/kira,died/system"
+	if got := strings.Join(events, ","); got != want {
+		t.Fatalf("timeline =\n%s\nesperaba\n%s", got, want)
+	}
+
+	// Borrar a la persona cierra la línea de tiempo, que sigue consultable
+	req = httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/v1/people/%d", id), nil)
+	req.Header.Set("If-Match", currentETag(t, router, fmt.Sprintf("/v1/people/%d", id)))
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+	events = getTimeline(t, s.GetRouter(), fmt.Sprintf("/v2/people/%d/timeline", id))
+	if events[len(events)-1] != "deleted/anonymous" {
+		t.Errorf("último evento = %s, esperaba deleted", events[len(events)-1])
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/people/999999/timeline", nil)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusNotFound)
+}
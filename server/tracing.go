@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gorm.io/gorm"
+)
+
+const (
+	tracerName = "backend-avanzada"
+
+	TracingNone   = "none"
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+	TracingMemory = "memory"
+)
+
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// initTracing crea el TracerProvider según Config.TracingExporter. Con "memory"
+// los spans quedan en memoria para las pruebas (ver SpansForTest)
+func (s *Server) initTracing() error {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch s.Config.TracingExporter {
+	case "", TracingNone:
+		s.setTracerProvider(noop.NewTracerProvider())
+		return nil
+	case TracingOTLP:
+		var opts []otlptracehttp.Option
+		if s.Config.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(s.Config.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case TracingStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case TracingMemory:
+		s.spanRecorder = &spanRecorder{}
+		exporter = s.spanRecorder
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", s.Config.TracingExporter)
+	}
+	if err != nil {
+		return err
+	}
+
+	res := resource.NewSchemaless(
+		semconv.ServiceName("deathnote-backend"),
+		semconv.ServiceVersion(Version),
+	)
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	if s.spanRecorder != nil {
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	} else {
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	tp := sdktrace.NewTracerProvider(opts...)
+	s.shutdownTracing = tp.Shutdown
+	s.setTracerProvider(tp)
+	return nil
+}
+
+func (s *Server) setTracerProvider(tp trace.TracerProvider) {
+	s.tracer = tp.Tracer(tracerName)
+	s.taskQueue.tracer = s.tracer
+}
+
+// SpansForTest devuelve los spans terminados cuando TracingExporter es "memory"
+func (s *Server) SpansForTest() []sdktrace.ReadOnlySpan {
+	if s.spanRecorder == nil {
+		return nil
+	}
+	return s.spanRecorder.spans()
+}
+
+// spanRecorder es el exportador "memory": guarda los spans terminados para
+// que las pruebas los inspeccionen
+type spanRecorder struct {
+	mu       sync.Mutex
+	recorded []sdktrace.ReadOnlySpan
+}
+
+func (r *spanRecorder) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded = append(r.recorded, spans...)
+	return nil
+}
+
+func (r *spanRecorder) Shutdown(context.Context) error {
+	return nil
+}
+
+func (r *spanRecorder) spans() []sdktrace.ReadOnlySpan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.recorded)
+}
+
+// tracing abre un span por petición nombrado con la plantilla de ruta y
+// continúa la traza recibida en traceparent si la hay
+func (s *Server) tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		route := routeTemplate(r)
+		ctx, span := s.tracer.Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rw := newResponseWriter(w)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rw.status))
+		if rw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.status))
+		}
+	})
+}
+
+// gormTracing es un plugin de GORM que abre un span por cada consulta usando
+// el contexto de la sentencia (ver WithContext en los repositorios)
+type gormTracing struct {
+	s *Server
+}
+
+const gormSpanKey = "otel:span"
+
+func (g *gormTracing) Name() string {
+	return "otel-tracing"
+}
+
+func (g *gormTracing) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		name   string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before("otel:before_"+h.name, g.before(h.name)); err != nil {
+			return err
+		}
+		if err := h.after("otel:after_"+h.name, g.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *gormTracing) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		_, span := g.s.tracer.Start(ctx, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(db.Dialector.Name()),
+				attribute.String("db.operation.name", operation),
+			),
+		)
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func (g *gormTracing) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBCollectionName(db.Statement.Table),
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,89 @@
+package server_test
+
+import (
+	"backend-avanzada/config"
+	"backend-avanzada/server"
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func findSpan(spans tracetest.SpanStubs, name string) *tracetest.SpanStub {
+	for i := range spans {
+		if spans[i].Name == name {
+			return &spans[i]
+		}
+	}
+	return nil
+}
+
+func TestTracingRequestDBAndTask(t *testing.T) {
+	cfg := &config.Config{
+		Database:                    "postgres",
+		KillDuration:                1,
+		KillDurationWithDescription: 4,
+		TracingExporter:             server.TracingMemory,
+	}
+	s := server.NewTestServer(cfg)
+	resetDB(s)
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("name", "Rem")
+	writer.WriteField("age", "30")
+	file, _ := os.Open("./testdata/light.jpg")
+	defer file.Close()
+	part, _ := writer.CreateFormFile("photo", "light.jpg")
+	io.Copy(part, file)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/people", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creación falló: %s", rec.Body.String())
+	}
+
+	// Esperar a que la tarea de ataque al corazón (1s) se ejecute
+	time.Sleep(1500 * time.Millisecond)
+	spans := tracetest.SpanStubsFromReadOnlySpans(s.SpansForTest())
+
+	httpSpan := findSpan(spans, "POST /people")
+	if httpSpan == nil {
+		t.Fatalf("no hay span HTTP, spans: %d", len(spans))
+	}
+	create := findSpan(spans, "gorm.create")
+	if create == nil || create.Parent.SpanID() != httpSpan.SpanContext.SpanID() {
+		t.Errorf("gorm.create no es hijo del span HTTP")
+	}
+
+	task := findSpan(spans, "task.execute")
+	if task == nil {
+		t.Fatal("no hay span de la tarea")
+	}
+	if task.SpanContext.TraceID() == httpSpan.SpanContext.TraceID() {
+		t.Error("la tarea debería tener su propia traza")
+	}
+	if len(task.Links) != 1 || task.Links[0].SpanContext.SpanID() != httpSpan.SpanContext.SpanID() {
+		t.Errorf("la tarea no enlaza con la petición que la encoló: %+v", task.Links)
+	}
+	// La petición también actualiza (registra la muerte programada); basta
+	// con que una de las actualizaciones cuelgue de la tarea
+	var update *tracetest.SpanStub
+	for i := range spans {
+		if spans[i].Name == "gorm.update" && spans[i].Parent.SpanID() == task.SpanContext.SpanID() {
+			update = &spans[i]
+		}
+	}
+	if update == nil {
+		t.Error("gorm.update no es hijo del span de la tarea")
+	}
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/audit"
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HandleTrash lista las personas borradas, de la más reciente a la más
+// antigua, con la fecha en que las purgará la retención
+func (s *Server) HandleTrash(w http.ResponseWriter, r *http.Request) error {
+	people, err := s.PeopleRepository.WithContext(r.Context()).FindDeleted()
+	if err != nil {
+		return err
+	}
+	result := make([]*api.TrashedPersonDto, 0, len(people))
+	for _, p := range people {
+		dto := &api.TrashedPersonDto{
+			Person:    p.ToPersonV2Dto(),
+			DeletedAt: p.DeletedAt.Time.Format(time.RFC3339),
+		}
+		if retention := s.trashRetention(); retention > 0 {
+			purgeAt := p.DeletedAt.Time.Add(retention).Format(time.RFC3339)
+			dto.PurgeAt = &purgeAt
+		}
+		result = append(result, dto)
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+func (s *Server) HandleRestorePerson(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.restorePerson(r)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, http.StatusOK, person, person.ToPersonResponseDto())
+	return nil
+}
+
+func (s *Server) handleV2RestorePerson(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.restorePerson(r)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, http.StatusOK, person, person.ToPersonV2Dto())
+	return nil
+}
+
+// restorePerson saca a la persona de la papelera y, si sigue pendiente,
+// vuelve a programar su muerte como si acabara de escribirse
+func (s *Server) restorePerson(r *http.Request) (*models.Person, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	ctx := r.Context()
+	person, err := s.PeopleRepository.WithContext(ctx).Unscoped().FindById(id)
+	if err != nil {
+		return nil, err
+	}
+	if person == nil {
+		return nil, apiErrorf(CodePersonNotFound, "person with id %d not found", id)
+	}
+	if !person.DeletedAt.Valid {
+		return nil, apiErrorf(CodeConflict, "person %d is not deleted", id)
+	}
+	err = s.UnitOfWork.WithContext(ctx).Do(func(tx *repository.Tx) error {
+		if person, err = tx.People.Restore(uint(id)); err != nil {
+			return err
+		}
+		if person == nil {
+			return apiErrorf(CodePersonNotFound, "person with id %d not found", id)
+		}
+		if err := s.rearmRestored(ctx, tx, person); err != nil {
+			return err
+		}
+		return tx.Events.Record(person.ID, models.EventRestored, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return person, nil
+}
+
+// rearmRestored programa en tx la muerte de una persona restaurada que
+// sigue pendiente, con los mismos plazos que al añadir detalles o causa
+func (s *Server) rearmRestored(ctx context.Context, tx *repository.Tx, person *models.Person) error {
+	id := int(person.ID)
+	switch {
+	case person.DeathTime != nil:
+		return nil
+	case person.Details != nil:
+		return s.scheduleDeath(ctx, tx, id, time.Duration(s.Config.KillDuration)*time.Second)
+	case person.Cause != nil:
+		return s.scheduleDeath(ctx, tx, id, time.Duration(s.Config.KillDurationWithDescription)*time.Second)
+	default:
+		return s.scheduleHeartAttack(ctx, tx, person)
+	}
+}
+
+// handlePurgePerson atiende DELETE /people/{id}?hard=true: borra para
+// siempre a la persona, esté viva o en la papelera. Una persona viva exige
+// If-Match como el borrado normal; en la papelera ya se confirmó al borrarla
+func (s *Server) handlePurgePerson(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r)
+	if err != nil {
+		return err
+	}
+	person, err := s.PeopleRepository.WithContext(r.Context()).Unscoped().FindById(id)
+	if err != nil {
+		return err
+	}
+	if person == nil {
+		return apiErrorf(CodePersonNotFound, "person with id %d not found", id)
+	}
+	if !person.DeletedAt.Valid {
+		if err := checkIfMatch(r, personETag(person)); err != nil {
+			return err
+		}
+	}
+	if err := s.purgePerson(r.Context(), uint(id)); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// hardDelete indica si el DELETE pide la purga definitiva
+func hardDelete(r *http.Request) bool {
+	hard, _ := strconv.ParseBool(r.URL.Query().Get("hard"))
+	return hard
+}
+
+// purgePerson cancela la tarea de la persona antes de purgarla, para que no
+// escriba sobre ella después, y borra su foto
+func (s *Server) purgePerson(ctx context.Context, id uint) error {
+	if _, err := s.TaskRepository.WithContext(ctx).Delete(id, ""); err != nil {
+		return err
+	}
+	s.taskQueue.CancelTask(ctx, int(id))
+	person, err := s.PeopleRepository.WithContext(ctx).Purge(id)
+	if err != nil {
+		return err
+	}
+	if person != nil {
+		s.removePhoto(ctx, person.PhotoPath)
+	}
+	return nil
+}
+
+func (s *Server) trashRetention() time.Duration {
+	return time.Duration(s.Config.TrashRetentionDays) * 24 * time.Hour
+}
+
+// PurgeExpiredTrash purga las personas que llevan en la papelera más que la
+// retención configurada; devuelve cuántas. Sin retención no hace nada
+func (s *Server) PurgeExpiredTrash(ctx context.Context) (int, error) {
+	retention := s.trashRetention()
+	if retention <= 0 {
+		return 0, nil
+	}
+	ctx = audit.WithActor(ctx, audit.ActorSystem)
+	expired, err := s.PeopleRepository.WithContext(ctx).FindDeletedBefore(time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	for i, person := range expired {
+		if err := s.purgePerson(ctx, person.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
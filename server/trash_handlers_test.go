@@ -0,0 +1,193 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"backend-avanzada/models"
+	"backend-avanzada/repository"
+)
+
+func serve(h http.Handler, method, path, ifMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+	created := createPersonAt(t, s, "/v2/people")
+	id := int(created["id"].(float64))
+	defer s.CancelTaskForTest(id)
+	path := fmt.Sprintf("/v2/people/%d", id)
+
+	if rec := serve(router, http.MethodDelete, path, currentETag(t, router, path)); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+	rec := serve(router, http.MethodGet, "/v2/people/trash", "")
+	var trash []struct {
+		Person    map[string]any `json:"person"`
+		DeletedAt string         `json:"deleted_at"`
+		PurgeAt   *string        `json:"purge_at"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &trash)
+	if len(trash) != 1 || trash[0].Person["id"] != float64(id) || trash[0].DeletedAt == "" || trash[0].PurgeAt != nil {
+		t.Fatalf("papelera inesperada: %s", rec.Body.String())
+	}
+
+	rec = serve(router, http.MethodPost, path+"/restore", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == "" {
+		t.Fatalf("restore: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(router, http.MethodGet, path, ""); rec.Code != http.StatusOK {
+		t.Errorf("tras restaurar: %d", rec.Code)
+	}
+	if rec := serve(router, http.MethodGet, "/v1/people/trash", ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("la papelera debería quedar vacía: %s", rec.Body.String())
+	}
+	decodeProblem(t, serve(router, http.MethodPost, path+"/restore", ""), http.StatusConflict)
+	decodeProblem(t, serve(router, http.MethodPost, "/v1/people/999999/restore", ""), http.StatusNotFound)
+
+	// El borrado canceló su muerte; sigue pendiente, así que se programa de nuevo
+	events := getTimeline(t, router, path+"/timeline")
+	if got := strings.Join(events[len(events)-4:], ","); got != "deleted/anonymous,task_cancelled/anonymous,restored/anonymous,task_scheduled/anonymous" {
+		t.Errorf("eventos tras restaurar: %s", got)
+	}
+}
+
+func TestDeleteCascadesToKills(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+	var ids []int
+	for range 2 {
+		created := createPersonAt(t, s, "/v2/people")
+		id := int(created["id"].(float64))
+		defer s.CancelTaskForTest(id)
+		ids = append(ids, id)
+	}
+	// La primera kill se revocó antes del borrado y no vuelve al restaurar
+	for _, description := range []string{"revocada", "cuaderno"} {
+		kill := &models.Kill{PersonId: uint(ids[0]), Description: description}
+		if _, err := s.KillRepository.Save(kill); err != nil {
+			t.Fatalf("kill: %v", err)
+		}
+		if description == "revocada" {
+			if err := s.KillRepository.Delete(kill); err != nil {
+				t.Fatalf("revocar: %v", err)
+			}
+		}
+	}
+	if _, err := s.KillRepository.Save(&models.Kill{PersonId: uint(ids[1]), Description: "otra"}); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	kills := func() []string {
+		var list []struct {
+			Description string `json:"description"`
+		}
+		json.Unmarshal(serve(router, http.MethodGet, "/v2/kills", "").Body.Bytes(), &list)
+		var descriptions []string
+		for _, k := range list {
+			descriptions = append(descriptions, k.Description)
+		}
+		return descriptions
+	}
+
+	path := fmt.Sprintf("/v2/people/%d", ids[0])
+	if rec := serve(router, http.MethodDelete, path, currentETag(t, router, path)); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+	}
+	if got := strings.Join(kills(), ","); got != "otra" {
+		t.Errorf("kills tras borrar: %s", got)
+	}
+	if _, err := s.KillRepository.Save(&models.Kill{PersonId: uint(ids[0])}); !errors.Is(err, repository.ErrPersonGone) {
+		t.Errorf("kill sobre una persona borrada: %v", err)
+	}
+
+	if rec := serve(router, http.MethodPost, path+"/restore", ""); rec.Code != http.StatusOK {
+		t.Fatalf("restore: %d %s", rec.Code, rec.Body.String())
+	}
+	if got := strings.Join(kills(), ","); got != "cuaderno,otra" && got != "otra,cuaderno" {
+		t.Errorf("kills tras restaurar: %s", got)
+	}
+	_, replayed, err := s.EventStore.Replay(uint(ids[0]), nil)
+	if err != nil || len(replayed) != 2 || !replayed[0].DeletedAt.Valid || replayed[1].DeletedAt.Valid {
+		t.Errorf("replay de las kills: %+v, %v", replayed, err)
+	}
+}
+
+func TestHardDeletePurgesEverything(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+	created := createPersonAt(t, s, "/v2/people")
+	id := int(created["id"].(float64))
+	defer s.CancelTaskForTest(id)
+	path := fmt.Sprintf("/v2/people/%d", id)
+	photo := filepath.Join("uploads", filepath.Base(created["photo_url"].(string)))
+	if _, err := s.KillRepository.Save(&models.Kill{PersonId: uint(id), Description: "cuaderno"}); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+
+	// Una persona viva necesita If-Match también para purgarla
+	decodeProblem(t, serve(router, http.MethodDelete, path+"?hard=true", ""), http.StatusPreconditionRequired)
+	if rec := serve(router, http.MethodDelete, path+"?hard=true", currentETag(t, router, path)); rec.Code != http.StatusNoContent {
+		t.Fatalf("hard delete: %d %s", rec.Code, rec.Body.String())
+	}
+
+	if _, err := os.Stat(photo); !os.IsNotExist(err) {
+		t.Errorf("la foto %s debería estar borrada: %v", photo, err)
+	}
+	var kills, events int64
+	s.DB.Unscoped().Model(&models.Kill{}).Where("person_id = ?", id).Count(&kills)
+	s.DB.Model(&models.DomainEvent{}).Where("stream_id = ?", id).Count(&events)
+	if kills != 0 || events != 0 {
+		t.Errorf("quedan %d kills y %d eventos", kills, events)
+	}
+	decodeProblem(t, serve(router, http.MethodGet, path+"/timeline", ""), http.StatusNotFound)
+	decodeProblem(t, serve(router, http.MethodDelete, path+"?hard=true", ""), http.StatusNotFound)
+}
+
+func TestRetentionPurgesExpiredTrash(t *testing.T) {
+	s := createTestServer(t)
+	router := s.GetRouter()
+	s.Config.TrashRetentionDays = 30
+	var ids []int
+	for range 2 {
+		created := createPersonAt(t, s, "/v2/people")
+		id := int(created["id"].(float64))
+		defer s.CancelTaskForTest(id)
+		path := fmt.Sprintf("/v2/people/%d", id)
+		if rec := serve(router, http.MethodDelete, path, currentETag(t, router, path)); rec.Code != http.StatusNoContent {
+			t.Fatalf("delete: %d %s", rec.Code, rec.Body.String())
+		}
+		ids = append(ids, id)
+	}
+	// La primera lleva en la papelera más que la retención
+	s.DB.Exec("UPDATE people SET deleted_at = ? WHERE id = ?", time.Now().AddDate(0, 0, -31), ids[0])
+
+	purged, err := s.PurgeExpiredTrash(context.Background())
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeExpiredTrash() = %d, %v", purged, err)
+	}
+	var trash []struct {
+		Person  map[string]any `json:"person"`
+		PurgeAt *string        `json:"purge_at"`
+	}
+	json.Unmarshal(serve(router, http.MethodGet, "/v2/people/trash", "").Body.Bytes(), &trash)
+	if len(trash) != 1 || trash[0].Person["id"] != float64(ids[1]) || trash[0].PurgeAt == nil {
+		t.Errorf("papelera tras la purga: %+v", trash)
+	}
+}
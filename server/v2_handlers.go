@@ -0,0 +1,130 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"net/http"
+	"time"
+)
+
+// Handlers de /v2. Reutilizan la lógica de /v1 (createPerson, addCause,
+// createKill...) y solo cambian la forma de la respuesta
+
+func (s *Server) handleV2ListPeople(w http.ResponseWriter, r *http.Request) error {
+	people, err := s.listPeople(r)
+	if err != nil {
+		return err
+	}
+	result := make([]*api.PersonV2Dto, 0, len(people))
+	for _, p := range people {
+		result = append(result, p.ToPersonV2Dto())
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+func (s *Server) handleV2CreatePerson(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.createPerson(w, r)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, http.StatusCreated, person, person.ToPersonV2Dto())
+	return nil
+}
+
+func (s *Server) handleV2GetPerson(w http.ResponseWriter, r *http.Request) error {
+	id, err := pathID(r)
+	if err != nil {
+		return err
+	}
+	person, err := s.findPersonAsOf(r, id)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, http.StatusOK, person, person.ToPersonV2Dto())
+	return nil
+}
+
+func (s *Server) handleV2EditPerson(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.editPerson(r)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, http.StatusOK, person, person.ToPersonV2Dto())
+	return nil
+}
+
+func (s *Server) handleV2PatchPerson(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.patchPerson(r)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, http.StatusOK, person, person.ToPersonV2Dto())
+	return nil
+}
+
+func (s *Server) handleV2ReplacePhoto(w http.ResponseWriter, r *http.Request) error {
+	person, err := s.replacePhoto(w, r)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, http.StatusOK, person, person.ToPersonV2Dto())
+	return nil
+}
+
+func (s *Server) handleV2AddCause(w http.ResponseWriter, r *http.Request) error {
+	id, err := s.addCause(r)
+	if err != nil {
+		return err
+	}
+	return s.writeV2Person(w, r, id, http.StatusAccepted)
+}
+
+func (s *Server) handleV2AddDetails(w http.ResponseWriter, r *http.Request) error {
+	id, err := s.addDetails(r)
+	if err != nil {
+		return err
+	}
+	return s.writeV2Person(w, r, id, http.StatusAccepted)
+}
+
+func (s *Server) handleV2ListKills(w http.ResponseWriter, r *http.Request) error {
+	kills, err := s.KillRepository.WithContext(r.Context()).FindAll()
+	if err != nil {
+		return err
+	}
+	result := make([]*api.KillV2Dto, 0, len(kills))
+	for _, k := range kills {
+		result = append(result, k.ToKillV2Dto())
+	}
+	return writeCollectionJSON(w, r, result)
+}
+
+func (s *Server) handleV2CreateKill(w http.ResponseWriter, r *http.Request) error {
+	kill, duration, err := s.createKill(r)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusCreated, &api.KillTaskV2Dto{
+		Person:     kill.Person.ToPersonV2Dto(),
+		Status:     "scheduled",
+		ExecutesAt: time.Now().Add(duration).Format(time.RFC3339),
+	})
+	return nil
+}
+
+func (s *Server) handleV2DeleteKill(w http.ResponseWriter, r *http.Request) error {
+	if err := s.revokeKill(r); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) writeV2Person(w http.ResponseWriter, r *http.Request, id int, status int) error {
+	person, err := s.findPerson(r.Context(), id)
+	if err != nil {
+		return err
+	}
+	writePersonJSON(w, r, status, person, person.ToPersonV2Dto())
+	return nil
+}
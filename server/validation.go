@@ -0,0 +1,234 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+func init() {
+	// Las fotos llegan como partes image/* dentro del multipart
+	for _, ct := range []string{"image/jpeg", "image/png", "image/gif", "image/webp"} {
+		openapi3filter.RegisterBodyDecoder(ct, openapi3filter.FileBodyDecoder)
+	}
+	openapi3filter.RegisterBodyDecoder("text/plain", formFieldDecoder)
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", openapi3filter.JSONBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/zip", openapi3filter.FileBodyDecoder)
+}
+
+// requestValidator valida parámetros y cuerpos contra api/openapi.json
+type requestValidator struct {
+	router routers.Router
+}
+
+func newRequestValidator() (*requestValidator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(api.OpenAPISpec)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid openapi.json: %w", err)
+	}
+	// Sin servers el router acepta cualquier host (tests, docker, proxy)
+	doc.Servers = nil
+	// La autenticación la hace requireAdmin; kin-openapi lee el cuerpo entero
+	// para cada requisito de seguridad, así que no se le dan
+	doc.Security = nil
+	for _, item := range doc.Paths.Map() {
+		for _, op := range item.Operations() {
+			op.Security = nil
+		}
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &requestValidator{router: router}, nil
+}
+
+// validateRequests rechaza con un único 400 las peticiones que no cumplen el
+// esquema, listando todos los errores. Las rutas que no están en la
+// especificación (p. ej. /static/) pasan sin validar
+func (s *Server) validateRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := s.validator.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// Un Content-Type que la operación no declara es un 415, no un error
+		// de esquema
+		if mediaType, ok := undeclaredMediaType(route.Operation, r); ok {
+			s.HandleError(w, r, apiErrorf(CodeUnsupportedMedia, "content type %q is not accepted by this operation", mediaType))
+			return
+		}
+		// kin-openapi lee el cuerpo entero a memoria antes que el handler y
+		// que requireAdmin: los ZIP no se validan (el esquema no dice nada de
+		// su contenido y cada handler los lee con su propio límite) y el resto
+		// se corta en maxUploadSize
+		zipBody := isZipBody(r)
+		if !zipBody {
+			if r.ContentLength > maxUploadSize {
+				s.HandleError(w, r, apiErrorf(CodePayloadTooLarge, "request body exceeds %d bytes", maxUploadSize))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+		}
+		err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:          true,
+				SkipSettingDefaults: true,
+				ExcludeRequestBody:  zipBody,
+				AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			},
+		})
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			s.HandleError(w, r, err)
+			return
+		}
+		if err != nil {
+			s.handleValidationError(w, r, fieldErrors(err))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isZipBody indica si la petición trae un ZIP (backup o importación masiva)
+func isZipBody(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/zip"
+}
+
+// undeclaredMediaType indica si la petición trae un cuerpo con un tipo que
+// la operación no admite
+func undeclaredMediaType(op *openapi3.Operation, r *http.Request) (string, bool) {
+	contentType := r.Header.Get("Content-Type")
+	if op == nil || op.RequestBody == nil || op.RequestBody.Value == nil || contentType == "" {
+		return "", false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType, true
+	}
+	return mediaType, op.RequestBody.Value.Content.Get(mediaType) == nil
+}
+
+func (s *Server) handleValidationError(w http.ResponseWriter, r *http.Request, errs []api.FieldError) {
+	s.HandleError(w, r, &APIError{
+		Code:   CodeValidationFailed,
+		Detail: fmt.Sprintf("%d field(s) do not match the API schema", len(errs)),
+		Errors: errs,
+	})
+}
+
+// fieldErrors aplana los errores de kin-openapi a una lista campo/mensaje.
+// Se usan aserciones de tipo y no errors.As porque MultiError y RequestError
+// se desenvuelven mutuamente y se perdería en qué parte de la petición falló
+func fieldErrors(err error) []api.FieldError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var out []api.FieldError
+		for _, inner := range e {
+			out = append(out, fieldErrors(inner)...)
+		}
+		return out
+	case *openapi3filter.RequestError:
+		switch {
+		case e.Parameter != nil:
+			return []api.FieldError{{
+				In:      e.Parameter.In,
+				Field:   e.Parameter.Name,
+				Message: reason(e),
+			}}
+		case e.RequestBody != nil && isSchemaError(e.Err):
+			return bodyErrors(e.Err)
+		}
+		return []api.FieldError{{In: "body", Message: reason(e)}}
+	}
+	return []api.FieldError{{In: "request", Message: err.Error()}}
+}
+
+func bodyErrors(err error) []api.FieldError {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var out []api.FieldError
+		for _, inner := range e {
+			out = append(out, bodyErrors(inner)...)
+		}
+		return out
+	case *openapi3.SchemaError:
+		return []api.FieldError{{
+			In:      "body",
+			Field:   strings.Join(e.JSONPointer(), "."),
+			Message: e.Reason,
+		}}
+	}
+	return []api.FieldError{{In: "body", Message: err.Error()}}
+}
+
+func isSchemaError(err error) bool {
+	switch err.(type) {
+	case openapi3.MultiError, *openapi3.SchemaError:
+		return true
+	}
+	return false
+}
+
+func reason(e *openapi3filter.RequestError) string {
+	if schemaErr, ok := e.Err.(*openapi3.SchemaError); ok {
+		return schemaErr.Reason
+	}
+	if e.Err != nil {
+		if e.Reason != "" {
+			return e.Reason + ": " + e.Err.Error()
+		}
+		return e.Err.Error()
+	}
+	return e.Reason
+}
+
+// formFieldDecoder lee los campos de texto del multipart y los convierte al
+// tipo del esquema, ya que en un formulario "age" llega como texto
+func formFieldDecoder(body io.Reader, header http.Header, schema *openapi3.SchemaRef, encFn openapi3filter.EncodingFn) (any, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, &openapi3filter.ParseError{Kind: openapi3filter.KindInvalidFormat, Cause: err}
+	}
+	value := string(data)
+	if schema == nil || schema.Value == nil {
+		return value, nil
+	}
+	switch {
+	case schema.Value.Type.Is("integer"):
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n, nil
+		}
+	case schema.Value.Type.Is("number"):
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f, nil
+		}
+	case schema.Value.Type.Is("boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b, nil
+		}
+	}
+	// Si no se puede convertir se deja el texto y el esquema reporta el tipo
+	return value, nil
+}
@@ -0,0 +1,150 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type validationBody struct {
+	Status int `json:"status"`
+	Errors []struct {
+		In      string `json:"in"`
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func decodeValidation(t *testing.T, rec *httptest.ResponseRecorder) *validationBody {
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("esperado 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var body validationBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("respuesta no es JSON: %s", rec.Body.String())
+	}
+	return &body
+}
+
+func TestValidationRejectsUnknownAndMissingFields(t *testing.T) {
+	s := createTestServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/people/1/cause", strings.NewReader(`{ "causa": "x" }`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+
+	body := decodeValidation(t, rec)
+	if len(body.Errors) < 2 {
+		t.Errorf("esperaba errores por campo desconocido y por falta de cause: %+v", body.Errors)
+	}
+	for _, e := range body.Errors {
+		if e.In != "body" {
+			t.Errorf("error fuera del body: %+v", e)
+		}
+	}
+}
+
+func TestValidationRejectsBadPathParam(t *testing.T) {
+	s := createTestServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/kills/abc", strings.NewReader(`{ "description": "" }`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+
+	body := decodeValidation(t, rec)
+	if len(body.Errors) != 1 || body.Errors[0].In != "path" || body.Errors[0].Field != "id" {
+		t.Errorf("esperaba un error en el parámetro id: %+v", body.Errors)
+	}
+}
+
+func TestValidationMultipartListsAllErrors(t *testing.T) {
+	s := createTestServer(t)
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("age", "0")
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/people", &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+
+	body := decodeValidation(t, rec)
+	fields := map[string]bool{}
+	for _, e := range body.Errors {
+		fields[e.Field] = true
+	}
+	for _, f := range []string{"name", "photo", "age"} {
+		if !fields[f] {
+			t.Errorf("falta el error de %q: %+v", f, body.Errors)
+		}
+	}
+}
+
+// zeroReader es un cuerpo de n ceros que cuenta lo que se lee de él
+type zeroReader struct {
+	n, read int64
+}
+
+func (z *zeroReader) Read(p []byte) (int, error) {
+	if z.read >= z.n {
+		return 0, io.EOF
+	}
+	n := int64(len(p))
+	if remaining := z.n - z.read; n > remaining {
+		n = remaining
+	}
+	clear(p[:n])
+	z.read += n
+	return int(n), nil
+}
+
+func TestValidationDoesNotBufferLargeBodies(t *testing.T) {
+	s := createTestServer(t)
+	s.Config.AdminToken = "secreto"
+	router := s.GetRouter()
+
+	// Un ZIP no se valida: sin token se rechaza sin leerlo
+	body := &zeroReader{n: 2 << 30}
+	req := httptest.NewRequest(http.MethodPost, "/admin/restore", body)
+	req.Header.Set("Content-Type", "application/zip")
+	req.ContentLength = body.n
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusUnauthorized)
+	if body.read != 0 {
+		t.Errorf("se leyeron %d bytes del backup", body.read)
+	}
+
+	// Con la longitud declarada ni se empieza a leer
+	body = &zeroReader{n: 1 << 30}
+	req = httptest.NewRequest(http.MethodPost, "/v1/people", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	req.ContentLength = body.n
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusRequestEntityTooLarge)
+	if body.read != 0 {
+		t.Errorf("se leyeron %d bytes", body.read)
+	}
+
+	// Sin ella se corta al pasar del límite
+	body = &zeroReader{n: 1 << 30}
+	req = httptest.NewRequest(http.MethodPost, "/v1/people", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusRequestEntityTooLarge)
+	if body.read > 11<<20 {
+		t.Errorf("se leyeron %d bytes", body.read)
+	}
+}
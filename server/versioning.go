@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// registerV1Routes registra la API tal como la consume hoy el frontend. Se
+// monta en /v1 y, marcada como obsoleta, en las rutas sin versión
+func (s *Server) registerV1Routes(router *mux.Router) {
+	// Rutas de personas
+	router.HandleFunc("/people", s.handle(s.idempotent(s.HandlePeople))).
+		Methods(http.MethodGet, http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/people/bulk", s.handle(s.HandleBulkCreatePeople)).
+		Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/people/trash", s.handle(s.HandleTrash)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/people/{id}", s.handle(s.HandlePeopleWithId)).
+		Methods(http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions)
+	router.HandleFunc("/people/{id}/cause", s.handle(s.HandleAddCause)).
+		Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/people/{id}/details", s.handle(s.HandleAddDetails)).
+		Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/people/{id}/photo", s.handle(s.HandleReplacePhoto)).
+		Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc("/people/{id}/status", s.handle(s.HandleGetStatus)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/people/{id}/timeline", s.handle(s.HandleTimeline)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/people/{id}/restore", s.handle(s.HandleRestorePerson)).
+		Methods(http.MethodPost, http.MethodOptions)
+
+	// Rutas de kills
+	router.HandleFunc("/kills", s.handle(s.HandleKills)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/kills/{id}", s.handle(s.idempotent(s.HandleKillsWithId))).
+		Methods(http.MethodPost, http.MethodDelete, http.MethodOptions)
+
+	// Ruta de configuración
+	router.HandleFunc("/config", s.HandleGetConfig).
+		Methods(http.MethodGet, http.MethodOptions)
+}
+
+// registerV2Routes registra la API con los DTOs de api/v2.go: GET
+// /people/{id} devuelve la persona completa, por lo que /status desaparece
+func (s *Server) registerV2Routes(router *mux.Router) {
+	router.HandleFunc("/people", s.handle(s.handleV2ListPeople)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/people", s.handle(s.idempotent(s.handleV2CreatePerson))).
+		Methods(http.MethodPost)
+	router.HandleFunc("/people/bulk", s.handle(s.HandleBulkCreatePeople)).
+		Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/people/trash", s.handle(s.HandleTrash)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/people/{id}", s.handle(s.handleV2GetPerson)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/people/{id}", s.handle(s.handleV2EditPerson)).
+		Methods(http.MethodPut)
+	router.HandleFunc("/people/{id}", s.handle(s.handleV2PatchPerson)).
+		Methods(http.MethodPatch)
+	router.HandleFunc("/people/{id}", s.handle(s.handleDeletePerson)).
+		Methods(http.MethodDelete)
+	router.HandleFunc("/people/{id}/photo", s.handle(s.handleV2ReplacePhoto)).
+		Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc("/people/{id}/cause", s.handle(s.handleV2AddCause)).
+		Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/people/{id}/details", s.handle(s.handleV2AddDetails)).
+		Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/people/{id}/timeline", s.handle(s.HandleTimeline)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/people/{id}/restore", s.handle(s.handleV2RestorePerson)).
+		Methods(http.MethodPost, http.MethodOptions)
+
+	router.HandleFunc("/kills", s.handle(s.handleV2ListKills)).
+		Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc("/kills/{id}", s.handle(s.idempotent(s.handleV2CreateKill))).
+		Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/kills/{id}", s.handle(s.handleV2DeleteKill)).
+		Methods(http.MethodDelete)
+
+	router.HandleFunc("/config", s.HandleGetConfig).
+		Methods(http.MethodGet, http.MethodOptions)
+}
+
+// defaultLegacyDeprecatedAt es la fecha en que se introdujo /v1, usada si
+// legacy_deprecated_at no está configurada
+const defaultLegacyDeprecatedAt = "2026-10-19T00:00:00Z"
+
+// deprecated añade Deprecation (RFC 9745), Sunset (RFC 8594) y un Link a la
+// ruta equivalente en /v1 a las respuestas de las rutas sin versión
+func (s *Server) deprecated(next http.Handler) http.Handler {
+	deprecation := httpStructuredDate(s.Config.LegacyDeprecatedAt)
+	if deprecation == "" {
+		deprecation = httpStructuredDate(defaultLegacyDeprecatedAt)
+	}
+	sunset := httpDate(s.Config.LegacySunsetAt)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", deprecation)
+		if sunset != "" {
+			w.Header().Set("Sunset", sunset)
+		}
+		w.Header().Add("Link", fmt.Sprintf(`</v1%s>; rel="successor-version"`, r.URL.Path))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// httpStructuredDate convierte una fecha RFC 3339 al formato "@<unix>" de
+// la cabecera Deprecation; devuelve "" si no está configurada o es inválida
+func httpStructuredDate(value string) string {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("@%d", t.Unix())
+}
+
+// httpDate convierte una fecha RFC 3339 al formato HTTP-date de Sunset
+func httpDate(value string) string {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(http.TimeFormat)
+}
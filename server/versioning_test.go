@@ -0,0 +1,139 @@
+package server_test
+
+import (
+	"backend-avanzada/config"
+	"backend-avanzada/server"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func createPersonAt(t *testing.T, s *server.Server, path string) map[string]any {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	writer.WriteField("name", "Mello")
+	writer.WriteField("age", "19")
+	file, _ := os.Open("./testdata/light.jpg")
+	defer file.Close()
+	part, _ := writer.CreateFormFile("photo", "light.jpg")
+	io.Copy(part, file)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST %s: esperado 201, got %d: %s", path, rec.Code, rec.Body.String())
+	}
+	var created map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	return created
+}
+
+func TestLegacyRoutesAreDeprecated(t *testing.T) {
+	cfg := &config.Config{
+		Database:                    "postgres",
+		KillDuration:                2,
+		KillDurationWithDescription: 4,
+		LegacyDeprecatedAt:          "2026-10-19T00:00:00Z",
+		LegacySunsetAt:              "2027-05-01T00:00:00Z",
+	}
+	s := server.NewTestServer(cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/people", nil)
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("esperado 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Deprecation"); got != "@1792368000" {
+		t.Errorf("Deprecation = %q", got)
+	}
+	if got := rec.Header().Get("Sunset"); got != "Sat, 01 May 2027 00:00:00 GMT" {
+		t.Errorf("Sunset = %q", got)
+	}
+	if got := rec.Header().Get("Link"); got != `</v1/people>; rel="successor-version"` {
+		t.Errorf("Link = %q", got)
+	}
+
+	for _, path := range []string{"/v1/people", "/v2/people", "/healthz"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: esperado 200, got %d", path, rec.Code)
+		}
+		if rec.Header().Get("Deprecation") != "" {
+			t.Errorf("%s no debería estar marcada como obsoleta", path)
+		}
+	}
+}
+
+func TestV1MatchesLegacyResponse(t *testing.T) {
+	s := createTestServer(t)
+	created := createPersonAt(t, s, "/v1/people")
+	id := strconv.Itoa(int(created["person_id"].(float64)))
+	s.CancelTaskForTest(int(created["person_id"].(float64)))
+
+	var bodies []string
+	for _, path := range []string{"/people/" + id, "/v1/people/" + id} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		s.GetRouter().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: esperado 200, got %d", path, rec.Code)
+		}
+		bodies = append(bodies, rec.Body.String())
+	}
+	if bodies[0] != bodies[1] {
+		t.Errorf("respuestas distintas:\n%s\n%s", bodies[0], bodies[1])
+	}
+}
+
+func TestV2ReturnsFullPerson(t *testing.T) {
+	s := createTestServer(t)
+	created := createPersonAt(t, s, "/v2/people")
+	id := int(created["id"].(float64))
+	s.CancelTaskForTest(id)
+	if created["status"] != "pending" || created["updated_at"] == nil {
+		t.Fatalf("respuesta inesperada: %v", created)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/v2/people/"+strconv.Itoa(id),
+		bytes.NewBufferString(`{"name":"Mihael","age":20}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", currentETag(t, s.GetRouter(), "/v2/people/"+strconv.Itoa(id)))
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT: esperado 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/people/"+strconv.Itoa(id), nil)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	var person map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &person)
+	if person["name"] != "Mihael" || person["status"] != "pending" {
+		t.Errorf("persona inesperada: %v", person)
+	}
+	for _, field := range []string{"cause", "details", "death_time", "photo_url", "created_at"} {
+		if _, ok := person[field]; !ok {
+			t.Errorf("falta %q en la respuesta de /v2", field)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/people/"+strconv.Itoa(id)+"/status", nil)
+	rec = httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("/v2 no tiene /status: esperado 404, got %d", rec.Code)
+	}
+}
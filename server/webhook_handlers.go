@@ -0,0 +1,153 @@
+package server
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/models"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HandleWebhooks lista las suscripciones (GET) o crea una (POST)
+func (s *Server) HandleWebhooks(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodPost:
+		return s.handleCreateWebhook(w, r)
+	default:
+		return s.handleGetAllWebhooks(w, r)
+	}
+}
+
+// HandleWebhookWithId devuelve (GET) o borra (DELETE) una suscripción
+func (s *Server) HandleWebhookWithId(w http.ResponseWriter, r *http.Request) error {
+	webhook, err := s.findWebhook(r)
+	if err != nil {
+		return err
+	}
+	if r.Method == http.MethodDelete {
+		if err := s.WebhookRepository.WithContext(r.Context()).Delete(webhook); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	writeJSON(w, http.StatusOK, webhook.ToWebhookDto())
+	return nil
+}
+
+func (s *Server) handleGetAllWebhooks(w http.ResponseWriter, r *http.Request) error {
+	webhooks, err := s.WebhookRepository.WithContext(r.Context()).FindAll()
+	if err != nil {
+		return err
+	}
+	result := make([]*api.WebhookDto, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		result = append(result, webhook.ToWebhookDto())
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	var req api.WebhookRequestDto
+	if err := decodeJSON(r, &req); err != nil {
+		return err
+	}
+	if err := s.webhooks.CheckTarget(r.Context(), req.URL); err != nil {
+		return apiErrorf(CodeBadRequest, "%v", err)
+	}
+	var events []string
+	for _, event := range req.Events {
+		if !slices.Contains(models.Topics, event) {
+			return apiErrorf(CodeBadRequest, "unknown event type %q", event)
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		return apiErrorf(CodeBadRequest, "events must not be empty")
+	}
+	if strings.TrimSpace(req.Secret) == "" {
+		return apiErrorf(CodeBadRequest, "secret must not be empty")
+	}
+	webhook, err := s.WebhookRepository.WithContext(r.Context()).Save(&models.Webhook{
+		URL:    req.URL,
+		Events: strings.Join(events, ","),
+		Secret: req.Secret,
+	})
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/webhooks/"+strconv.FormatUint(uint64(webhook.ID), 10))
+	writeJSON(w, http.StatusCreated, webhook.ToWebhookDto())
+	return nil
+}
+
+// HandleWebhookDeliveries devuelve el registro de entregas de la
+// suscripción, del intento más reciente al más antiguo
+func (s *Server) HandleWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	webhook, err := s.findWebhook(r)
+	if err != nil {
+		return err
+	}
+	limit := defaultAuditMax
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			return apiErrorf(CodeBadRequest, "limit must be between 1 and %d", maxAuditLimit)
+		}
+	}
+	deliveries, err := s.WebhookRepository.WithContext(r.Context()).Deliveries(webhook.ID, limit)
+	if err != nil {
+		return err
+	}
+	result := make([]*api.WebhookDeliveryDto, 0, len(deliveries))
+	for _, d := range deliveries {
+		result = append(result, d.ToWebhookDeliveryDto())
+	}
+	writeJSON(w, http.StatusOK, result)
+	return nil
+}
+
+// HandleWebhookTest envía en el momento una notificación webhook.test
+// firmada y devuelve el resultado, que también queda en el registro de
+// entregas. No se reintenta
+func (s *Server) HandleWebhookTest(w http.ResponseWriter, r *http.Request) error {
+	webhook, err := s.findWebhook(r)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(map[string]uint{"webhook_id": webhook.ID})
+	if err != nil {
+		return err
+	}
+	d, err := s.webhooks.Send(r.Context(), webhook, &api.NotificationDto{
+		Type:       models.TopicWebhookTest,
+		OccurredAt: time.Now().Format(time.RFC3339Nano),
+		Data:       data,
+	}, 1)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, d.ToWebhookDeliveryDto())
+	return nil
+}
+
+func (s *Server) findWebhook(r *http.Request) (*models.Webhook, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := s.WebhookRepository.WithContext(r.Context()).FindById(id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, apiErrorf(CodeWebhookNotFound, "webhook %d not found", id)
+	}
+	return webhook, nil
+}
@@ -0,0 +1,214 @@
+package server_test
+
+import (
+	"backend-avanzada/api"
+	"backend-avanzada/config"
+	"backend-avanzada/models"
+	"backend-avanzada/outbox"
+	"backend-avanzada/server"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver comprueba la firma de cada envío y responde status
+type webhookReceiver struct {
+	t      *testing.T
+	secret string
+	mu     sync.Mutex
+	status int
+	events []string
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if got := r.Header.Get(outbox.SignatureHeader); got != outbox.Sign(rc.secret, body) {
+		rc.t.Errorf("firma %q no coincide", got)
+	}
+	var n api.NotificationDto
+	json.Unmarshal(body, &n)
+	if n.Type != r.Header.Get(outbox.EventHeader) {
+		rc.t.Errorf("%s = %q, cuerpo con %q", outbox.EventHeader, r.Header.Get(outbox.EventHeader), n.Type)
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.events = append(rc.events, n.Type)
+	w.WriteHeader(rc.status)
+}
+
+func (rc *webhookReceiver) received() string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return strings.Join(rc.events, ",")
+}
+
+// webhookRequest hace una petición a /webhooks con el token de admin
+func webhookRequest(s *server.Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+s.Config.AdminToken)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	return rec
+}
+
+func listDeliveries(t *testing.T, s *server.Server, id uint) []api.WebhookDeliveryDto {
+	rec := webhookRequest(s, http.MethodGet, fmt.Sprintf("/webhooks/%d/deliveries", id), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET deliveries: %d %s", rec.Code, rec.Body.String())
+	}
+	var deliveries []api.WebhookDeliveryDto
+	json.Unmarshal(rec.Body.Bytes(), &deliveries)
+	return deliveries
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	// El receptor escucha en loopback: solo se admite con webhook_allow_private
+	s := server.NewTestServer(&config.Config{
+		Database:                    "postgres",
+		KillDuration:                2,
+		KillDurationWithDescription: 4,
+		AdminToken:                  "secreto",
+		WebhookAllowPrivate:         true,
+	})
+	resetDB(s)
+	receiver := &webhookReceiver{t: t, secret: "shinigami", status: http.StatusOK}
+	target := httptest.NewServer(receiver)
+	defer target.Close()
+
+	decodeProblem(t, webhookRequest(s, http.MethodPost, "/webhooks", `{"url": "`+target.URL+`", "events": ["person.resurrected"], "secret": "shinigami"}`), http.StatusBadRequest)
+	decodeProblem(t, webhookRequest(s, http.MethodPost, "/webhooks", `{"url": "ftp://ryuk", "events": ["person.died"], "secret": "shinigami"}`), http.StatusBadRequest)
+	rec := webhookRequest(s, http.MethodPost, "/webhooks", `{"url": "`+target.URL+`", "events": ["person.created", "person.died"], "secret": "shinigami"}`)
+	if rec.Code != http.StatusCreated || strings.Contains(rec.Body.String(), "shinigami") {
+		t.Fatalf("POST /webhooks: %d %s", rec.Code, rec.Body.String())
+	}
+	var webhook api.WebhookDto
+	json.Unmarshal(rec.Body.Bytes(), &webhook)
+
+	rec = webhookRequest(s, http.MethodPost, fmt.Sprintf("/webhooks/%d/test", webhook.ID), "")
+	var test api.WebhookDeliveryDto
+	json.Unmarshal(rec.Body.Bytes(), &test)
+	if rec.Code != http.StatusOK || !test.Success || test.StatusCode != http.StatusOK || test.Event != models.TopicWebhookTest {
+		t.Fatalf("POST test: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// El receptor cae: el alta queda pendiente y el fallo en el registro
+	receiver.mu.Lock()
+	receiver.status = http.StatusServiceUnavailable
+	receiver.mu.Unlock()
+	person, err := s.PeopleRepository.Save(&models.Person{Name: "Naomi", Age: 27})
+	if err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if delivered, err := s.Outbox.Flush(context.Background()); err != nil || delivered != 0 {
+		t.Fatalf("Flush() = %d, %v", delivered, err)
+	}
+	if d := listDeliveries(t, s, webhook.ID)[0]; d.Success || d.StatusCode != http.StatusServiceUnavailable || d.Attempt != 1 || d.Event != models.TopicPersonCreated {
+		t.Fatalf("primer intento: %+v", d)
+	}
+
+	// El reintento la entrega; kill.created no es de la suscripción
+	receiver.mu.Lock()
+	receiver.status = http.StatusNoContent
+	receiver.mu.Unlock()
+	if _, err := s.KillRepository.Save(&models.Kill{PersonId: person.ID}); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	// La suscripción que falló espera a su RetryAt; aquí se adelanta
+	s.DB.Model(&models.OutboxMessage{}).Where("1 = 1").Update("next_attempt_at", person.CreatedAt)
+	s.DB.Model(&models.OutboxDelivery{}).Where("1 = 1").Update("next_attempt_at", person.CreatedAt)
+	s.DB.Model(&models.Webhook{}).Where("1 = 1").Update("retry_at", person.CreatedAt)
+	if delivered, err := s.Outbox.Flush(context.Background()); err != nil || delivered != 2 {
+		t.Fatalf("Flush() = %d, %v", delivered, err)
+	}
+	if got := receiver.received(); got != "webhook.test,person.created,person.created" {
+		t.Errorf("recibidos %q", got)
+	}
+	deliveries := listDeliveries(t, s, webhook.ID)
+	if len(deliveries) != 3 || !deliveries[0].Success || deliveries[0].Attempt != 2 || deliveries[0].MessageID == 0 {
+		t.Errorf("registro: %+v", deliveries)
+	}
+
+	if rec := webhookRequest(s, http.MethodDelete, fmt.Sprintf("/webhooks/%d", webhook.ID), ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: %d %s", rec.Code, rec.Body.String())
+	}
+	if p := decodeProblem(t, webhookRequest(s, http.MethodGet, fmt.Sprintf("/webhooks/%d", webhook.ID), ""), http.StatusNotFound); p.Code != "webhook_not_found" {
+		t.Errorf("code = %q", p.Code)
+	}
+}
+
+func TestWebhooksRequireAdminAndPublicTargets(t *testing.T) {
+	s := createTestServer(t)
+	s.Config.AdminToken = "secreto"
+	body := `{"url": "https://93.184.215.14/hooks", "events": ["person.died"], "secret": "shinigami"}`
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.GetRouter().ServeHTTP(rec, req)
+	decodeProblem(t, rec, http.StatusUnauthorized)
+	decodeProblem(t, serve(s.GetRouter(), http.MethodPost, "/webhooks/1/test", ""), http.StatusUnauthorized)
+
+	for _, target := range []string{"http://127.0.0.1:8000/hooks", "http://10.0.0.7/hooks", "http://169.254.169.254/latest/meta-data"} {
+		rec := webhookRequest(s, http.MethodPost, "/webhooks", `{"url": "`+target+`", "events": ["person.died"], "secret": "shinigami"}`)
+		if p := decodeProblem(t, rec, http.StatusBadRequest); !strings.Contains(p.Detail, "public address") {
+			t.Errorf("%s: detail = %q", target, p.Detail)
+		}
+	}
+	if rec := webhookRequest(s, http.MethodPost, "/webhooks", body); rec.Code != http.StatusCreated {
+		t.Errorf("una IP pública debería aceptarse: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestWebhookFailingSubscriptionBacksOffAlone(t *testing.T) {
+	s := server.NewTestServer(&config.Config{
+		Database:                    "postgres",
+		KillDuration:                2,
+		KillDurationWithDescription: 4,
+		AdminToken:                  "secreto",
+		WebhookAllowPrivate:         true,
+	})
+	resetDB(s)
+	down := &webhookReceiver{t: t, secret: "shinigami", status: http.StatusServiceUnavailable}
+	up := &webhookReceiver{t: t, secret: "shinigami", status: http.StatusOK}
+	var ids []uint
+	for _, receiver := range []*webhookReceiver{down, up} {
+		target := httptest.NewServer(receiver)
+		defer target.Close()
+		rec := webhookRequest(s, http.MethodPost, "/webhooks", `{"url": "`+target.URL+`", "events": ["person.created"], "secret": "shinigami"}`)
+		var webhook api.WebhookDto
+		json.Unmarshal(rec.Body.Bytes(), &webhook)
+		ids = append(ids, webhook.ID)
+	}
+	if _, err := s.PeopleRepository.Save(&models.Person{Name: "Mikami", Age: 24}); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+
+	// Dos pasadas seguidas: la caída espera a su RetryAt y la sana no repite
+	for i := 0; i < 2; i++ {
+		s.DB.Model(&models.OutboxMessage{}).Where("1 = 1").Update("next_attempt_at", time.Unix(0, 0))
+		s.DB.Model(&models.OutboxDelivery{}).Where("1 = 1").Update("next_attempt_at", time.Unix(0, 0))
+		if _, err := s.Outbox.Flush(context.Background()); err != nil {
+			t.Fatalf("Flush() error: %v", err)
+		}
+	}
+	if down.received() != "person.created" || up.received() != "person.created" {
+		t.Errorf("recibidos: caída %q, sana %q", down.received(), up.received())
+	}
+	m := listOutbox(t, s.GetRouter(), "")[0]
+	if m.Status != models.OutboxPending || m.Deliveries[0].Attempts != 0 || !strings.Contains(m.LastError, fmt.Sprintf("webhook %d", ids[0])) {
+		t.Errorf("mensaje: %+v", m)
+	}
+	if d := listDeliveries(t, s, ids[1]); len(d) != 1 || !d[0].Success {
+		t.Errorf("entregas de la sana: %+v", d)
+	}
+}